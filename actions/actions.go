@@ -5,13 +5,43 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/juju/loggo"
 	"github.com/perrito666/goworkon/environment"
 	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/lockfile"
 	"github.com/perrito666/goworkon/paths"
 	"github.com/pkg/errors"
 )
 
+var logger = loggo.GetLogger("goworkon.actions")
+
+// storeLockTimeout is how long to wait for another process changing
+// configs or settings.
+const storeLockTimeout = 30 * time.Second
+
+// lockStore takes the lock that serializes changes to configs and
+// settings, the returned function releases it.
+func lockStore() (func(), error) {
+	lockDir, err := paths.XdgDataLocks()
+	if err != nil {
+		return nil, errors.Wrap(err, "determining locks folder")
+	}
+	if err := os.MkdirAll(lockDir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "creating locks folder %q", lockDir)
+	}
+	lock, err := lockfile.Acquire(filepath.Join(lockDir, "store.lock"), storeLockTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "locking configs and settings")
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}, nil
+}
+
 func globalBins() ([]string, error) {
 	basePath, err := paths.XdgDataConfig()
 	if err != nil {
@@ -54,60 +84,249 @@ func configGet(name string) (environment.Config, error) {
 	return env, nil
 }
 
-func ensureCanUpdateTo(goVersion goinstalls.Version) error {
-	dataDir, err := paths.XdgData()
+// ensureCanUpdateTo installs goVersion if necessary and returns the
+// name of the install it resolved to.
+func ensureCanUpdateTo(goVersion string, settings environment.Settings, opts goinstalls.InstallOptions) (string, error) {
+	name, err := ensureVersionInstalled(goVersion, settings, opts)
+	if err != nil {
+		return "", errors.Wrapf(err, "installing go %q", goVersion)
+	}
+	return name, nil
+}
+
+// installOptions fills opts, which holds what is chosen for each run such as
+// the platform and the progress reporter, with settings.
+func installOptions(settings environment.Settings, opts goinstalls.InstallOptions) (goinstalls.InstallOptions, error) {
+	cacheDir, err := paths.XdgCacheDownloads()
+	if err != nil {
+		return goinstalls.InstallOptions{}, errors.Wrap(err, "determining download cache folder")
+	}
+	lockDir, err := paths.XdgDataLocks()
 	if err != nil {
-		return errors.Wrap(err, "finding data dir")
+		return goinstalls.InstallOptions{}, errors.Wrap(err, "determining locks folder")
 	}
+	if err := configureNetwork(settings); err != nil {
+		return goinstalls.InstallOptions{}, errors.WithStack(err)
+	}
+	opts.FromSource = settings.FromSource
+	opts.Goroot = settings.Goroot
+	opts.CacheDir = cacheDir
+	opts.Offline = settings.Offline
+	opts.BuildMode = settings.BuildMode
+	opts.LockDir = lockDir
+	opts.GoEnv = settings.GoEnv
+	return opts, nil
+}
+
+// configureNetwork sets how go releases are downloaded according to
+// settings.
+func configureNetwork(settings environment.Settings) error {
+	opts := goinstalls.NetworkOptions{
+		Mirrors:      settings.ActiveMirrors(),
+		CABundle:     settings.CABundle,
+		Proxy:        settings.Proxy,
+		GoProxy:      settings.ActiveGoProxy(),
+		SumDB:        settings.GoSumDB,
+		TrustGoProxy: settings.TrustGoProxy,
+	}
+	if settings.Timeout != "" {
+		timeout, err := time.ParseDuration(settings.Timeout)
+		if err != nil {
+			return errors.Wrapf(err, "parsing timeout %q", settings.Timeout)
+		}
+		opts.Timeout = timeout
+	}
+	return errors.Wrap(goinstalls.ConfigureNetwork(opts), "configuring downloads")
+}
 
-	settings, err := environment.LoadSettings(dataDir)
+// availableReleases returns all the go releases that can be installed,
+// those in the download cache when offline.
+func availableReleases(settings environment.Settings) (map[goinstalls.Version]goinstalls.Release, error) {
+	if !settings.Offline {
+		if err := configureNetwork(settings); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return goinstalls.OnlineReleases()
+	}
+	cacheDir, err := paths.XdgCacheDownloads()
 	if err != nil {
-		return errors.Wrap(err, "loading settings")
+		return nil, errors.Wrap(err, "determining download cache folder")
+	}
+	return goinstalls.CachedReleases(cacheDir)
+}
+
+// installed returns true if there is a complete install in installPath,
+// an error is returned if the install is there but broken.
+func installed(installPath string) (bool, error) {
+	if _, err := os.Lstat(installPath); err != nil {
+		return false, nil
 	}
+	if broken := goinstalls.CheckInstall(installPath); broken != "" {
+		return false, errors.Errorf("the install in %q is broken (%s), run: goworkon repair %s",
+			installPath, broken, filepath.Base(installPath))
+	}
+	return true, nil
+}
 
-	_, err = ensureVersionInstalled(goVersion.String(), settings.Goroot)
+// ensureRunsOnHost returns an error if the install called installName
+// cannot run on this host, only those can back an environment.
+func ensureRunsOnHost(installName string) error {
+	installFolder, err := paths.XdgDataGoInstalls()
 	if err != nil {
-		return errors.Wrapf(err, "installing go %q", goVersion)
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	installPath := filepath.Join(installFolder, installName)
+	info, err := goinstalls.ReadInstallInfo(installPath)
+	if err != nil || info.Platform().IsHost() {
+		return nil
 	}
-	return nil
+	return errors.Wrapf(goinstalls.RunsOnHost(installPath),
+		"go %q is for %s, environments can only use installs that run on this host", installName, info.Platform())
 }
 
-func ensureVersionInstalled(goVersion, goroot string) (goinstalls.Version, error) {
-	goFolder, err := paths.XdgDataGoInstallsBinForVerson(goVersion)
+// installRelease installs the given release in installFolder, picking the
+// go to bootstrap it if it is built from source, versions are the releases
+// available to install.
+func installRelease(v goinstalls.Version, release goinstalls.Release,
+	versions map[goinstalls.Version]goinstalls.Release, installFolder string, opts goinstalls.InstallOptions) error {
+	if opts.FromSource {
+		var err error
+		opts.Goroot, err = goinstalls.Bootstrap(v, versions, installFolder, opts)
+		if err != nil {
+			return errors.Wrapf(err, "finding a go to build %q", v.String())
+		}
+	}
+	return errors.Wrapf(goinstalls.InstallVersion(v, release, installFolder, opts), "installing go %q", v.String())
+}
+
+// resolvedVersion is a requested go version resolved to its install.
+type resolvedVersion struct {
+	installName string
+	// installed is true if there is a usable install already, version
+	// and release are not set then.
+	installed bool
+	version   goinstalls.Version
+	release   goinstalls.Release
+	// variant is the build variant requested, if any.
+	variant goinstalls.Variant
+}
+
+// options returns opts adjusted to install r.
+func (r resolvedVersion) options(opts goinstalls.InstallOptions) goinstalls.InstallOptions {
+	if r.variant.Name != "" {
+		opts.Variant = r.variant
+		opts.FromSource = true
+	}
+	return opts
+}
+
+// resolveVersion resolves goVersion, a constraint as understood by
+// goinstalls.ParseConstraint or the name of an install, optionally
+// followed by the name of a build variant in settings as in
+// goinstalls.WithVariant, to the install for platform that satisfies it,
+// releases returns the releases available and is only called if goVersion
+// is not installed.
+func resolveVersion(goVersion, installFolder string, settings environment.Settings, platform goinstalls.Platform,
+	releases func() (map[goinstalls.Version]goinstalls.Release, error)) (resolvedVersion, error) {
+	base, variantName := goinstalls.SplitVariant(goVersion)
+	installName := goVersion
+	if v, err := goinstalls.VersionFromString(base); err == nil && !v.Language {
+		installName = goinstalls.WithVariant(goinstalls.InstallName(v, platform), variantName)
+	}
+	ok, err := installed(filepath.Join(installFolder, installName))
 	if err != nil {
-		return goinstalls.Version{}, errors.Wrapf(err, "determining if %q exists", goVersion)
+		return resolvedVersion{}, errors.WithStack(err)
+	}
+	if ok {
+		return resolvedVersion{installName: installName, installed: true}, nil
+	}
+	var variant goinstalls.Variant
+	if variantName != "" {
+		if variant, err = settings.Variant(variantName); err != nil {
+			return resolvedVersion{}, errors.WithStack(err)
+		}
+		if !platform.IsHost() {
+			return resolvedVersion{}, errors.Errorf("build variant %q cannot be installed for %s, only for this host",
+				variantName, platform.Resolved())
+		}
 	}
-	// if the bin folder is not there, the version is not properly
-	// installed.
-	if _, err := os.Stat(goFolder); err == nil {
-		return goinstalls.VersionFromString(goVersion)
+	constraint, err := goinstalls.ParseConstraint(base)
+	if err != nil {
+		return resolvedVersion{}, errors.Wrapf(err, "%q is neither an install nor a go version", goVersion)
 	}
-	versions, err := goinstalls.OnlineAvailableVersions()
+	versions, err := releases()
 	if err != nil {
-		return goinstalls.Version{}, errors.Wrap(err, "retrieving available online versions")
+		return resolvedVersion{}, errors.Wrap(err, "retrieving available versions")
+	}
+	v, release, ok := constraint.Resolve(versions)
+	if !ok {
+		return resolvedVersion{}, errors.Errorf("no go version satisfies %q", goVersion)
+	}
+	r := resolvedVersion{
+		installName: goinstalls.WithVariant(goinstalls.InstallName(v, platform), variantName),
+		version:     v,
+		release:     release,
+		variant:     variant,
+	}
+	r.installed, err = installed(filepath.Join(installFolder, r.installName))
+	return r, errors.WithStack(err)
+}
+
+// cachedReleases returns a function that returns the releases available,
+// they are only retrieved the first time it is called.
+func cachedReleases(settings environment.Settings) func() (map[goinstalls.Version]goinstalls.Release, error) {
+	var versions map[goinstalls.Version]goinstalls.Release
+	return func() (map[goinstalls.Version]goinstalls.Release, error) {
+		if versions != nil {
+			return versions, nil
+		}
+		var err error
+		versions, err = availableReleases(settings)
+		return versions, err
 	}
+}
+
+// ensureVersionInstalled installs the release that satisfies goVersion, a
+// constraint as understood by goinstalls.ParseConstraint, if necessary and
+// returns the name of its install, goVersion can also name an install.
+// Versions are installed with opts filled with settings, see installOptions.
+func ensureVersionInstalled(goVersion string, settings environment.Settings, opts goinstalls.InstallOptions) (string, error) {
 	installFolder, err := paths.XdgDataGoInstalls()
 	if err != nil {
-		return goinstalls.Version{}, errors.Wrapf(err, "determining go installs folder to install %q", goVersion)
+		return "", errors.Wrapf(err, "determining go installs folder to install %q", goVersion)
 	}
-	reqVersion, err := goinstalls.VersionFromString(goVersion)
+	releases := cachedReleases(settings)
+	r, err := resolveVersion(goVersion, installFolder, settings, opts.Platform, releases)
 	if err != nil {
-		return goinstalls.Version{}, errors.Wrapf(err, "parsing the requested version %q", goVersion)
+		return "", errors.WithStack(err)
 	}
-	for k, v := range versions {
-		minorMatch := reqVersion.Patch == 0 && k.CommonVersionString() == goVersion
-		fullMatch := reqVersion.SameVersion(k)
-		if minorMatch || fullMatch {
-			goFolder = filepath.Join(installFolder, k.String())
-			if _, err := os.Stat(goFolder); err == nil {
-				return k, nil
-			}
-			err = goinstalls.InstallVersion(k, v, installFolder, goroot)
-			return k, errors.Wrapf(err, "installing go %q", goVersion)
-		}
+	if r.installed {
+		return r.installName, nil
+	}
+	versions, err := releases()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	opts, err = installOptions(settings, opts)
+	if err != nil {
+		return "", errors.WithStack(err)
 	}
-	return goinstalls.Version{}, errors.Errorf("go version %q not found", goVersion)
+	err = installRelease(r.version, r.release, versions, installFolder, r.options(opts))
+	return r.installName, errors.Wrapf(err, "installing go %q", goVersion)
+}
 
+// recordedConstraint returns what an environment using goVersion should
+// record to be updated later, exact versions and install names pin the
+// environment so nothing is recorded for them, build variants are kept.
+func recordedConstraint(goVersion string) string {
+	constraint, _ := goinstalls.SplitVariant(goVersion)
+	if v, err := goinstalls.VersionFromString(constraint); err == nil && !v.Language {
+		return ""
+	}
+	if _, err := goinstalls.ParseConstraint(constraint); err != nil {
+		return ""
+	}
+	return goVersion
 }
 
 func extractEnvironment(attribute string) (string, string, error) {
@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// Adopt registers the go tree in goroot as the install of its version,
+// the tree is used in place and never modified.
+func Adopt(goroot string, settings environment.Settings) error {
+	installFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrapf(err, "determining go installs folder to adopt %q", goroot)
+	}
+	opts, err := installOptions(settings, goinstalls.InstallOptions{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	v, err := goinstalls.Adopt(goroot, installFolder, opts)
+	if err != nil {
+		return errors.Wrapf(err, "adopting %q", goroot)
+	}
+	fmt.Printf("adopted %q as go %s\n", goroot, v.String())
+	return nil
+}
@@ -1,32 +1,95 @@
 package actions
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/perrito666/goworkon/paths"
 	"github.com/pkg/errors"
 )
 
-// Create creates the an environment with the passed name
-// in the passed go version, if it exists its a noop and
-// returns an error.
-func Create(installName, goVersion, goPath string, settings environment.Settings) error {
+// environmentExists returns an error if the environment already exists.
+func environmentExists(installName string) error {
 	_, err := configGet(installName)
-	if err != nil && !isNotFound(err) {
+	if err == nil {
+		return errors.Errorf("environment %q already exists", installName)
+	}
+	if !isNotFound(err) {
 		return errors.Wrapf(err, "determining if environment %q exists", installName)
 	}
-	v, err := ensureVersionInstalled(goVersion, settings.Goroot)
+	return nil
+}
+
+// goModVersion returns the go version required by the toolchain line of
+// the go.mod in goPath or, if there is none, in the working folder, the
+// newest stable go is used if neither declares one.
+func goModVersion(goPath string) (string, error) {
+	for _, dir := range []string{goPath, "."} {
+		goMod := filepath.Join(dir, goinstalls.GOMODFILE)
+		if _, err := os.Stat(goMod); err != nil {
+			continue
+		}
+		v, ok, err := goinstalls.GoModToolchain(goMod)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if ok {
+			logger.Infof("using go %s as required by %q", v.String(), goMod)
+			return v.String(), nil
+		}
+	}
+	return goinstalls.CHANNELSTABLE, nil
+}
+
+// Create creates the an environment with the passed name
+// in the passed go version, if it exists its a noop and
+// returns an error. If no go version is passed the one required by
+// the toolchain line of the project go.mod is used, see goModVersion.
+func Create(installName, goVersion, goPath string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	if err := environmentExists(installName); err != nil {
+		return errors.WithStack(err)
+	}
+	// nothing is installed for an environment that could not use it.
+	if !opts.Platform.IsHost() {
+		return errors.Errorf("go for %s cannot be used by %q, environments can only use installs that run on this host",
+			opts.Platform.Resolved(), installName)
+	}
+	if goVersion == "" {
+		var err error
+		if goVersion, err = goModVersion(goPath); err != nil {
+			return errors.Wrapf(err, "determining the go version for %q", installName)
+		}
+	}
+	goInstall, err := ensureVersionInstalled(goVersion, settings, opts)
 	if err != nil {
 		return errors.Wrapf(err, "installing go %q to create %q environment", goVersion, installName)
 	}
+	// the go.mod toolchain or an install name can still resolve to an
+	// install for another platform.
+	if err := ensureRunsOnHost(goInstall); err != nil {
+		return errors.WithStack(err)
+	}
 	c := environment.Config{
-		Name:      installName,
-		GoVersion: v.String(),
-		GoPath:    goPath,
+		Name:         installName,
+		GoVersion:    goInstall,
+		GoConstraint: recordedConstraint(goVersion),
+		GoPath:       goPath,
 	}
 	configPath, err := paths.XdgDataConfig()
 	if err != nil {
 		return errors.Wrapf(err, "getting config folder to save %q config", installName)
 	}
+	unlock, err := lockStore()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer unlock()
+	// it might have been created while go was being installed.
+	if err := environmentExists(installName); err != nil {
+		return errors.WithStack(err)
+	}
 	if err := c.Save(configPath); err != nil {
 		return errors.Wrapf(err, "saving %q config", installName)
 	}
@@ -0,0 +1,153 @@
+package actions
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// installResult is how the install of one of the requested go versions
+// went.
+type installResult struct {
+	goVersion   string
+	installName string
+	// existed is true if the install was there already.
+	existed bool
+	err     error
+}
+
+// String returns the summary line of the result.
+func (r installResult) String() string {
+	switch {
+	case r.err != nil:
+		return fmt.Sprintf("%s\tfailed: %v", r.goVersion, r.err)
+	case r.existed:
+		return fmt.Sprintf("%s\tgo %s was already installed", r.goVersion, r.installName)
+	}
+	return fmt.Sprintf("%s\tgo %s is installed", r.goVersion, r.installName)
+}
+
+// Install installs the given go versions, constraints as understood by
+// ensureVersionInstalled, up to settings.InstallJobs() of them at the same
+// time, those already installed are left alone.
+// When several versions are passed a summary of how each install went is
+// printed at the end and an error is returned if any of them failed.
+func Install(goVersions []string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	installFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	releases := cachedReleases(settings)
+	results := make([]installResult, len(goVersions))
+	resolved := map[int]resolvedVersion{}
+	// several versions can resolve to the same install, it is done once.
+	firstOf := map[string]int{}
+	pending := []int{}
+	for i, goVersion := range goVersions {
+		results[i].goVersion = goVersion
+		r, err := resolveVersion(goVersion, installFolder, settings, opts.Platform, releases)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		results[i].installName = r.installName
+		results[i].existed = r.installed
+		if _, ok := firstOf[r.installName]; ok || r.installed {
+			continue
+		}
+		firstOf[r.installName] = i
+		resolved[i] = r
+		pending = append(pending, i)
+	}
+
+	if len(pending) > 0 {
+		versions, err := releases()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		opts, err = installOptions(settings, opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < settings.InstallJobs() && w < len(pending); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					r := resolved[i]
+					results[i].err = installRelease(r.version, r.release, versions, installFolder, r.options(opts))
+				}
+			}()
+		}
+		for _, i := range pending {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	failed := 0
+	for i := range results {
+		if first, ok := firstOf[results[i].installName]; ok && first != i {
+			results[i].err = results[first].err
+		}
+		if results[i].err != nil {
+			failed++
+		}
+	}
+	if len(results) == 1 {
+		if results[0].err != nil {
+			return errors.Wrapf(results[0].err, "installing go %q", results[0].goVersion)
+		}
+		fmt.Printf("go %s is installed\n", results[0].installName)
+		return nil
+	}
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of the %d go versions requested failed to install", failed, len(results))
+	}
+	return nil
+}
+
+// InstallCustom builds the go tree described by src into a custom install
+// called name, or named after its revision if name is empty.
+func InstallCustom(src goinstalls.CustomSource, name string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	installFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrapf(err, "determining go installs folder to install %q", src.Location)
+	}
+	opts, err = installOptions(settings, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// the releases are only needed if there is no install to build with.
+	bootstrap := func(v goinstalls.Version) (string, error) {
+		if _, needed := goinstalls.BootstrapRequirement(v); !needed {
+			return "", nil
+		}
+		goroot, ok, err := goinstalls.FindBootstrap(v, installFolder)
+		if err != nil || ok {
+			return goroot, errors.WithStack(err)
+		}
+		versions, err := availableReleases(settings)
+		if err != nil {
+			return "", errors.Wrap(err, "retrieving available versions")
+		}
+		return goinstalls.Bootstrap(v, versions, installFolder, opts)
+	}
+	installName, err := goinstalls.InstallCustom(src, name, installFolder, bootstrap, opts)
+	if err != nil {
+		return errors.Wrapf(err, "installing go from %q", src.Location)
+	}
+	fmt.Printf("go %s is installed, use it with: goworkon --go-version=%s create <envname> <gopath>\n",
+		installName, installName)
+	return nil
+}
@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// humanSize returns a human readable representation of size bytes.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// environmentsByVersion returns the names of the environments using each
+// go version.
+func environmentsByVersion() (map[string][]string, error) {
+	basePath, err := paths.XdgDataConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving config files")
+	}
+	cfgs, err := environment.LoadConfig(basePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading configs")
+	}
+	byVersion := map[string][]string{}
+	for _, cfg := range cfgs {
+		byVersion[cfg.GoVersion] = append(byVersion[cfg.GoVersion], cfg.Name)
+	}
+	for _, names := range byVersion {
+		sort.Strings(names)
+	}
+	return byVersion, nil
+}
+
+// Installs prints a list of the go installs along with their size, date,
+// the environments using them and the recipe of build variants.
+func Installs() error {
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	installs, err := goinstalls.Installs(installsFolder)
+	if err != nil {
+		return errors.Wrap(err, "finding go installs")
+	}
+	byVersion, err := environmentsByVersion()
+	if err != nil {
+		return errors.Wrap(err, "finding environments for installs")
+	}
+	for _, install := range installs {
+		line := fmt.Sprintf("%s\t%s\t%s", install.Name, humanSize(install.Size),
+			install.Installed.Format("2006-01-02 15:04"))
+		if envs := byVersion[install.Name]; len(envs) > 0 {
+			line = fmt.Sprintf("%s\tused by: %s", line, strings.Join(envs, ", "))
+		}
+		if install.Info.Variant != nil {
+			line = fmt.Sprintf("%s\tvariant %s: %s", line, install.Variant, *install.Info.Variant)
+		}
+		if install.Info.Origin != "" {
+			line = fmt.Sprintf("%s\tfrom %s: %s", line, install.Info.Source, install.Info.Origin)
+		}
+		if install.Broken != "" {
+			line = fmt.Sprintf("%s\tBROKEN: %s", line, install.Broken)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
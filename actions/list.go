@@ -2,6 +2,7 @@ package actions
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/perrito666/goworkon/environment"
 	"github.com/perrito666/goworkon/paths"
@@ -19,12 +20,24 @@ func List() error {
 		return errors.Wrap(err, "loading configis for listing")
 	}
 	for _, cfg := range cfgs {
-		fmt.Println(fmt.Sprintf("(%s) %q:%s", cfg.GoVersion, cfg.Name, cfg.GoPath))
+		goVersion := cfg.GoVersion
+		if cfg.GoConstraint != "" {
+			goVersion = fmt.Sprintf("%s from %s", cfg.GoVersion, cfg.GoConstraint)
+		}
+		fmt.Println(fmt.Sprintf("(%s) %q:%s", goVersion, cfg.Name, cfg.GoPath))
 		if len(cfg.CompileSteps) > 0 {
 			for i, step := range cfg.CompileSteps {
-				fmt.Println("_%d: %q", i, step)
+				fmt.Printf("_%d: %q\n", i, step)
 			}
 		}
+		names := make([]string, 0, len(cfg.Vars))
+		for name := range cfg.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("$%s=%q\n", name, cfg.Vars[name])
+		}
 	}
 	return nil
 }
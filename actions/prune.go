@@ -0,0 +1,69 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// Prune removes the go installs that no environment uses and are not kept
+// by the passed policy, if dryRun is true it only prints what would be
+// removed. The install of the default environment in settings is never
+// removed, nothing is if its environment cannot be loaded.
+func Prune(policy goinstalls.RetentionPolicy, dryRun bool, settings environment.Settings) error {
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	installs, err := goinstalls.Installs(installsFolder)
+	if err != nil {
+		return errors.Wrap(err, "finding go installs")
+	}
+	byVersion, err := environmentsByVersion()
+	if err != nil {
+		return errors.Wrap(err, "finding environments for installs")
+	}
+	inUse := make(map[string]bool, len(byVersion))
+	for version := range byVersion {
+		inUse[version] = true
+	}
+	defaultInstall := ""
+	if settings.Default != "" {
+		cfg, err := configGet(settings.Default)
+		if err != nil {
+			return errors.Wrapf(err, "finding go version of default environment %q", settings.Default)
+		}
+		defaultInstall = cfg.GoVersion
+		found := false
+		for _, install := range installs {
+			found = found || install.Name == defaultInstall
+		}
+		if !found {
+			logger.Warningf("the default environment %q uses go %q, which is not installed", settings.Default, defaultInstall)
+		}
+	}
+
+	var freed int64
+	for _, install := range goinstalls.Prunable(installs, inUse, defaultInstall, policy, time.Now()) {
+		freed += install.Size
+		if dryRun {
+			fmt.Printf("would remove %s (%s)\n", install.Name, humanSize(install.Size))
+			continue
+		}
+		if err := os.RemoveAll(install.Path); err != nil {
+			return errors.Wrapf(err, "removing go %q", install.Name)
+		}
+		fmt.Printf("removed %s (%s)\n", install.Name, humanSize(install.Size))
+	}
+	if dryRun {
+		fmt.Printf("%s would be freed\n", humanSize(freed))
+		return nil
+	}
+	fmt.Printf("%s freed\n", humanSize(freed))
+	return nil
+}
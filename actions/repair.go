@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// Repair reinstalls the given go version if its install is broken, the
+// same kind of install (prebuilt or from source) is made if known, build
+// variants are built again with the recipe they were made with, or the one
+// in settings if unknown.
+func Repair(goVersion string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	installFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrapf(err, "determining go installs folder to repair %q", goVersion)
+	}
+	installPath := filepath.Join(installFolder, goVersion)
+	broken := goinstalls.CheckInstall(installPath)
+	if broken == "" {
+		fmt.Printf("go %s is not broken, nothing to repair\n", goVersion)
+		return nil
+	}
+	if info, err := goinstalls.ReadInstallInfo(installPath); err == nil && info.Adopted() {
+		return errors.Errorf("go %s was adopted from %q, goworkon does not modify it, fix it and adopt it again: goworkon adopt <goroot>",
+			goVersion, info.Origin)
+	}
+	v, platform, err := goinstalls.ParseInstallName(goVersion)
+	if err != nil {
+		return errors.Wrapf(err, "%q is not an install that can be repaired", goVersion)
+	}
+	versions, err := availableReleases(settings)
+	if err != nil {
+		return errors.Wrap(err, "retrieving available versions")
+	}
+	release, ok := versions[v]
+	if !ok {
+		return errors.Errorf("go version %q not found", goVersion)
+	}
+	opts, err = installOptions(settings, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	opts.Platform = platform
+	// broken installs do not have info, but repairing a complete one
+	// that fails the checks should not change how it was made.
+	info, err := goinstalls.ReadInstallInfo(installPath)
+	if err == nil {
+		opts.FromSource = info.Source == goinstalls.SOURCEBUILD
+	}
+	if _, variant := goinstalls.SplitVariant(goVersion); variant != "" {
+		if info.Variant != nil {
+			opts.Variant = *info.Variant
+		} else if opts.Variant, err = settings.Variant(variant); err != nil {
+			return errors.WithStack(err)
+		}
+		opts.FromSource = true
+	}
+	fmt.Printf("repairing go %s (%s)\n", goVersion, broken)
+	return errors.Wrapf(installRelease(v, release, versions, installFolder, opts), "repairing go %q", goVersion)
+}
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/perrito666/goworkon/paths"
 	"github.com/pkg/errors"
 )
@@ -12,14 +13,17 @@ func Set(attribute, value string) error {
 	if err != nil {
 		return errors.Errorf("setting %q to %q", attribute, value)
 	}
+	unlock, err := lockStore()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer unlock()
 	if environmentName != "" {
 		cfg, err := configGet(environmentName)
 		if err != nil {
 			return errors.Wrapf(err, "finding config for %q", environmentName)
 		}
 		return errors.Wrapf(cfg.Set(attribute, value), "setting %q to %q", attribute, value)
-
-		return errors.New("setting environment attributes is not implemented")
 	}
 
 	settingsFolder, err := paths.XdgData()
@@ -31,5 +35,29 @@ func Set(attribute, value string) error {
 		return errors.Wrap(err, "loading settings")
 	}
 
-	return errors.Wrapf(settings.Set(attribute, value), "setting %q to %q", attribute, value)
+	if err := settings.Set(attribute, value); err != nil {
+		return errors.Wrapf(err, "setting %q to %q", attribute, value)
+	}
+	if _, _, ok := environment.GoEnvAttribute(attribute); ok {
+		return errors.WithStack(applyGoEnv(settingsFolder))
+	}
+	return nil
+}
+
+// applyGoEnv writes the go.env defaults in the settings saved in
+// settingsFolder into every install.
+func applyGoEnv(settingsFolder string) error {
+	settings, err := environment.LoadSettings(settingsFolder)
+	if err != nil {
+		return errors.Wrap(err, "loading settings")
+	}
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	opts, err := installOptions(settings, goinstalls.InstallOptions{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.Wrap(goinstalls.ApplyGoEnv(installsFolder, settings.GoEnv, opts), "applying go.env defaults")
 }
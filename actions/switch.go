@@ -1,7 +1,10 @@
 package actions
 
 import (
+	"path/filepath"
+
 	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/perrito666/goworkon/goswitch"
 	"github.com/perrito666/goworkon/paths"
 	"github.com/pkg/errors"
@@ -25,10 +28,23 @@ func Switch(installName string) error {
 		return errors.Wrapf(err, "loading settings to switch to %q", installName)
 	}
 
+	if err := ensureRunsOnHost(env.GoVersion); err != nil {
+		return errors.Wrapf(err, "switching to environment %q", installName)
+	}
+
 	extraBins, err := globalBins()
 	if err != nil {
 		return errors.Wrap(err, "determining global bin paths")
 	}
+	// record the use so prune can keep recently used installs, failing
+	// to do so is no reason to not switch.
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err == nil {
+		err = goinstalls.MarkUsed(filepath.Join(installsFolder, env.GoVersion))
+	}
+	if err != nil {
+		logger.Warningf("cannot record use of go %q: %v", env.GoVersion, err)
+	}
 	return errors.Wrapf(goswitch.Switch(env, installName == settings.Default, extraBins),
 		"switching to environment %q", installName)
 }
@@ -9,18 +9,34 @@ import (
 	"github.com/pkg/errors"
 )
 
-func update(v goinstalls.Version, envs []string) error {
+// update points envs to the install called installName and records
+// the constraint it was resolved from, a nil constraint leaves the
+// recorded ones untouched.
+func update(installName string, constraint *string, envs []string) error {
+	if err := ensureRunsOnHost(installName); err != nil {
+		return errors.WithStack(err)
+	}
 	cfgData, err := paths.XdgDataConfig()
 	if err != nil {
 		return errors.Wrap(err, "getting path for config files")
 	}
+	unlock, err := lockStore()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer unlock()
 	for _, env := range envs {
 		cfg, err := configGet(env)
 		if err != nil {
-			return errors.Wrapf(err, "updating %q to %q", env, v.String)
+			return errors.Wrapf(err, "updating %q to %q", env, installName)
+		}
+		cfg.GoVersion = installName
+		if constraint != nil {
+			cfg.GoConstraint = *constraint
+		}
+		if err := cfg.Save(cfgData); err != nil {
+			return errors.Wrapf(err, "saving %q config", env)
 		}
-		cfg.GoVersion = v.String()
-		cfg.Save(cfgData)
 		for _, step := range cfg.CompileSteps {
 			fmt.Println(step)
 			// TODO(perrito666) switch and run compile steps
@@ -29,22 +45,24 @@ func update(v goinstalls.Version, envs []string) error {
 	return nil
 }
 
-// UpdateToVersion updates the given environment to the given version or
-// returns an error if not possible
-func UpdateToVersion(environmentName string, version goinstalls.Version) error {
-	fmt.Printf("will update %q to %q\n", environmentName, version.String())
+// UpdateToVersion updates the given environment to the given version, or
+// custom install, or returns an error if not possible
+func UpdateToVersion(environmentName, goVersion string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	fmt.Printf("will update %q to %q\n", environmentName, goVersion)
 	_, err := configGet(environmentName)
 	if err != nil {
 		return errors.Wrapf(err, "determining if environment %q exists", environmentName)
 	}
-	if err := ensureCanUpdateTo(version); err != nil {
-		return errors.Wrapf(err, "installing go %q to update %q environment", version.String(), environmentName)
+	installed, err := ensureCanUpdateTo(goVersion, settings, opts)
+	if err != nil {
+		return errors.Wrapf(err, "installing go %q to update %q environment", goVersion, environmentName)
 	}
 
-	return errors.Wrapf(update(version, []string{environmentName}), "updating %q to version %q", environmentName, version.String())
+	constraint := recordedConstraint(goVersion)
+	return errors.Wrapf(update(installed, &constraint, []string{environmentName}), "updating %q to version %q", environmentName, installed)
 }
 
-func matchingVersion(v goinstalls.Version, haystack map[goinstalls.Version]string) (goinstalls.Version, bool) {
+func matchingVersion(v goinstalls.Version, haystack map[goinstalls.Version]goinstalls.Release) (goinstalls.Version, bool) {
 	for k := range haystack {
 		if v.CommonVersionString() == k.CommonVersionString() {
 			return k, true
@@ -54,8 +72,9 @@ func matchingVersion(v goinstalls.Version, haystack map[goinstalls.Version]strin
 }
 
 // UpdateAllTo will update all environments that share the common version
-// to the passed patch.
-func UpdateAllTo(version goinstalls.Version) error {
+// to the passed patch, keeping their build variants, environments using
+// custom installs are left alone.
+func UpdateAllTo(version goinstalls.Version, settings environment.Settings, opts goinstalls.InstallOptions) error {
 	basePath, err := paths.XdgDataConfig()
 	if err != nil {
 		return errors.Wrap(err, "retrieving configs for listing")
@@ -64,48 +83,63 @@ func UpdateAllTo(version goinstalls.Version) error {
 	if err != nil {
 		return errors.Wrap(err, "loading configis for listing")
 	}
-	updateables := []string{}
+	// environments are updated to the same build variant they use.
+	updateables := map[string][]string{}
 	for _, cfg := range cfgs {
-		v, err := goinstalls.VersionFromString(cfg.GoVersion)
+		goVersion, variant := goinstalls.SplitVariant(cfg.GoVersion)
+		v, err := goinstalls.VersionFromString(goVersion)
 		if err != nil {
-			return errors.Wrapf(err, "finding version for %q", cfg.Name)
+			logger.Debugf("not updating %q, it uses the custom install %q", cfg.Name, cfg.GoVersion)
+			continue
 		}
 		if version.CommonVersionString() == v.CommonVersionString() {
-			updateables = append(updateables, cfg.Name)
+			updateables[variant] = append(updateables[variant], cfg.Name)
 		}
 	}
 	if len(updateables) > 0 {
-		if version.Patch == 0 {
-			versions, err := goinstalls.OnlineAvailableVersions()
+		if version.Language {
+			versions, err := availableReleases(settings)
 			if err != nil {
 				return errors.Wrap(err, "fetching available versions for udpate")
 			}
 			var ok bool
-			version, ok = matchingVersion(version, versions)
+			version, ok = matchingVersion(version, goinstalls.NewestPatches(versions))
 			if !ok {
 				return errors.Errorf("unavailable version %q", version.String())
 			}
 		}
-		if err := ensureCanUpdateTo(version); err != nil {
-			return errors.Wrapf(err, "installing go %q to update environments", version)
+		for variant, envs := range updateables {
+			goVersion := goinstalls.WithVariant(version.String(), variant)
+			installed, err := ensureCanUpdateTo(goVersion, settings, opts)
+			if err != nil {
+				return errors.Wrapf(err, "installing go %q to update environments", goVersion)
+			}
+			if err := update(installed, nil, envs); err != nil {
+				return errors.Wrapf(err, "updating all versions %q", installed)
+			}
 		}
-
-		return errors.Wrapf(update(version, updateables), "updating all versions %q", version.String())
 	}
 	return nil
 
 }
 
-// UpdateToLatest will update the environment to the latest available version
-// of go
-func UpdateToLatest(environmentName string) error {
-	version, _, err := goinstalls.NewestAvailableOnline()
+// UpdateToLatest will update the environment to the newest go that satisfies
+// its recorded constraint, or the newest stable go, of the same build
+// variant, if it has none.
+func UpdateToLatest(environmentName string, settings environment.Settings, opts goinstalls.InstallOptions) error {
+	cfg, err := configGet(environmentName)
 	if err != nil {
-		return errors.Wrap(err, "obtaining latest go version")
+		return errors.Wrapf(err, "determining if environment %q exists", environmentName)
+	}
+	constraint := cfg.GoConstraint
+	if constraint == "" {
+		_, variant := goinstalls.SplitVariant(cfg.GoVersion)
+		constraint = goinstalls.WithVariant(goinstalls.CHANNELSTABLE, variant)
 	}
-	if err := ensureCanUpdateTo(version); err != nil {
-		return errors.Wrapf(err, "installing go %q to update environment %q", version.String, environmentName)
+	installed, err := ensureCanUpdateTo(constraint, settings, opts)
+	if err != nil {
+		return errors.Wrapf(err, "installing go %q to update environment %q", constraint, environmentName)
 	}
 
-	return errors.Wrapf(update(version, []string{environmentName}), "updating %q to version %q", environmentName, version.String())
+	return errors.Wrapf(update(installed, &cfg.GoConstraint, []string{environmentName}), "updating %q to version %q", environmentName, installed)
 }
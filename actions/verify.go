@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// printFiles prints the files of the given kind, indented, under the
+// summary of an install.
+func printFiles(kind string, files []string) {
+	for _, f := range files {
+		fmt.Printf("\t%s: %s\n", kind, f)
+	}
+}
+
+// Verify checks the install called installName, or all of them if empty,
+// against the manifest recorded when it was made and prints the files
+// changed, missing or added since. An error is returned if any install
+// does not match its manifest or, when one is named, cannot be verified.
+func Verify(installName string) error {
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return errors.Wrap(err, "determining go installs folder")
+	}
+	names := []string{installName}
+	if installName == "" {
+		installs, err := goinstalls.Installs(installsFolder)
+		if err != nil {
+			return errors.Wrap(err, "finding go installs")
+		}
+		names = []string{}
+		for _, install := range installs {
+			if install.Broken != "" {
+				fmt.Printf("%s\tskipped, it is broken: %s\n", install.Name, install.Broken)
+				continue
+			}
+			names = append(names, install.Name)
+		}
+	}
+	failed := []string{}
+	for _, name := range names {
+		result, err := goinstalls.VerifyInstall(filepath.Join(installsFolder, name))
+		if err != nil {
+			if installName != "" {
+				return errors.Wrapf(err, "verifying go %q", name)
+			}
+			fmt.Printf("%s\tcannot be verified: %v\n", name, err)
+			continue
+		}
+		if result.OK() {
+			fmt.Printf("%s\tok\n", name)
+			continue
+		}
+		failed = append(failed, name)
+		fmt.Printf("%s\tmodified: %d changed, %d missing, %d extra files\n",
+			name, len(result.Changed), len(result.Missing), len(result.Extra))
+		printFiles("changed", result.Changed)
+		printFiles("missing", result.Missing)
+		printFiles("extra", result.Extra)
+		if result.VersionMismatch != "" {
+			fmt.Printf("\tversion: %s\n", result.VersionMismatch)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("go %s do not match the manifest recorded when installed", strings.Join(failed, ", "))
+	}
+	return nil
+}
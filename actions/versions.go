@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/perrito666/goworkon/paths"
+	"github.com/pkg/errors"
+)
+
+// versionEntry is a go version as listed by Versions.
+type versionEntry struct {
+	Version string `json:"version"`
+	// Stable is false for betas and release candidates.
+	Stable bool `json:"stable"`
+	// Installs holds the names of the usable installs of this version, one
+	// per platform.
+	Installs []string `json:"installs"`
+	// Environments holds the names of the environments using those installs.
+	Environments []string `json:"environments"`
+}
+
+// installsByVersion returns the usable installs of go releases grouped by
+// version, custom installs are left out.
+func installsByVersion() (map[goinstalls.Version][]goinstalls.Install, error) {
+	installsFolder, err := paths.XdgDataGoInstalls()
+	if err != nil {
+		return nil, errors.Wrap(err, "determining go installs folder")
+	}
+	installs, err := goinstalls.Installs(installsFolder)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding go installs")
+	}
+	byVersion := map[goinstalls.Version][]goinstalls.Install{}
+	for _, install := range installs {
+		if install.Broken != "" || install.Info.Custom() {
+			continue
+		}
+		byVersion[install.Version] = append(byVersion[install.Version], install)
+	}
+	return byVersion, nil
+}
+
+// versionEntries returns an entry for each of versions, in the same order,
+// marking their installs and the environments using them.
+func versionEntries(versions []goinstalls.Version, installs map[goinstalls.Version][]goinstalls.Install,
+	envs map[string][]string) []versionEntry {
+	entries := make([]versionEntry, 0, len(versions))
+	for _, v := range versions {
+		entry := versionEntry{
+			Version:      v.String(),
+			Stable:       v.IsRelease(),
+			Installs:     []string{},
+			Environments: []string{},
+		}
+		for _, install := range installs[v] {
+			entry.Installs = append(entry.Installs, install.Name)
+			entry.Environments = append(entry.Environments, envs[install.Name]...)
+		}
+		sort.Strings(entry.Installs)
+		sort.Strings(entry.Environments)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Versions prints the go versions installed, newest first, or those that
+// can be installed if remote is true, only the newest stable release of
+// each minor unless all is true. Each version is marked with its installs
+// and the environments using them, the list is printed as JSON if asJSON
+// is true.
+func Versions(remote, all, asJSON bool, settings environment.Settings) error {
+	installs, err := installsByVersion()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	envs, err := environmentsByVersion()
+	if err != nil {
+		return errors.Wrap(err, "finding environments for versions")
+	}
+	var versions goinstalls.Versions
+	if remote {
+		releases, err := availableReleases(settings)
+		if err != nil {
+			return errors.Wrap(err, "retrieving available versions")
+		}
+		if !all {
+			releases = goinstalls.NewestPatches(releases)
+		}
+		versions = goinstalls.SortedVersions(releases)
+	} else {
+		for v := range installs {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(versions))
+	}
+	entries := versionEntries(versions, installs, envs)
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return errors.Wrap(encoder.Encode(entries), "writing versions")
+	}
+	for _, entry := range entries {
+		line := entry.Version
+		if !entry.Stable {
+			line = fmt.Sprintf("%s\tunstable", line)
+		}
+		if len(entry.Installs) > 0 {
+			line = fmt.Sprintf("%s\tinstalled: %s", line, strings.Join(entry.Installs, ", "))
+		}
+		if len(entry.Environments) > 0 {
+			line = fmt.Sprintf("%s\tused by: %s", line, strings.Join(entry.Environments, ", "))
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
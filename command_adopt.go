@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
+	"github.com/pkg/errors"
+)
+
+// Adopt command registers a go tree installed by other means.
+type Adopt struct {
+	goroot   string
+	settings environment.Settings
+}
+
+// Usage implements Command.
+func (a Adopt) Usage() string {
+	return "the expected format is: goworkon adopt <goroot>"
+}
+
+// Validate implements Command.
+func (a Adopt) Validate() error {
+	if a.goroot == "" {
+		return errors.New("missing the goroot to adopt")
+	}
+	return nil
+}
+
+// Run implements Command.
+func (a Adopt) Run() error {
+	return errors.WithStack(actions.Adopt(a.goroot, a.settings))
+}
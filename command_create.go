@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/perrito666/goworkon/actions"
 	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/pkg/errors"
 )
 
@@ -12,11 +13,12 @@ type Create struct {
 	goVersion       string
 	goPath          string
 	settings        environment.Settings
+	opts            goinstalls.InstallOptions
 }
 
 // Usage implements Command.
 func (c Create) Usage() string {
-	return "the expected format is: goworkon [Options] create <envname>"
+	return "the expected format is: goworkon [Options] [--os=<goos>] [--arch=<goarch>] create <envname> <gopath>"
 }
 
 // Validate implements Command.
@@ -24,18 +26,15 @@ func (c Create) Validate() error {
 	if c.environmentName == "" {
 		return errors.New("missing environment name")
 	}
-	if c.goVersion == "" {
-		return errors.New("missing go version")
-	}
 	if c.goPath == "" {
 		return errors.New("missing gopath/workspace for the environment")
 	}
-	return nil
+	return errors.WithStack(c.opts.Platform.Validate())
 }
 
 // Run implements Command.
 func (c Create) Run() error {
-	err := actions.Create(c.environmentName, c.goVersion, c.goPath, c.settings)
+	err := actions.Create(c.environmentName, c.goVersion, c.goPath, c.settings, c.opts)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/pkg/errors"
+)
+
+// Install command installs a go release or builds a custom install from
+// a local tree, a git repository or an archive.
+type Install struct {
+	goVersions  []string
+	fromDir     string
+	fromGit     string
+	ref         string
+	fromArchive string
+	name        string
+	settings    environment.Settings
+	opts        goinstalls.InstallOptions
+}
+
+// Usage implements Command.
+func (i Install) Usage() string {
+	return "the expected format is: goworkon [Options] [--os=<goos>] [--arch=<goarch>] [--jobs=N] install <version>... or goworkon " +
+		"[--name=<name>] install --from-dir=<goroot>|--from-git=<repo> [--ref=<ref>]|--from-archive=<file.tar.gz>"
+}
+
+// source returns the custom source requested, if any.
+func (i Install) source() (goinstalls.CustomSource, bool) {
+	switch {
+	case i.fromDir != "":
+		return goinstalls.CustomSource{Kind: goinstalls.SOURCEDIR, Location: i.fromDir}, true
+	case i.fromGit != "":
+		return goinstalls.CustomSource{Kind: goinstalls.SOURCEGIT, Location: i.fromGit, Ref: i.ref}, true
+	case i.fromArchive != "":
+		return goinstalls.CustomSource{Kind: goinstalls.SOURCEARCHIVE, Location: i.fromArchive}, true
+	}
+	return goinstalls.CustomSource{}, false
+}
+
+// Validate implements Command.
+func (i Install) Validate() error {
+	sources := 0
+	for _, s := range []string{i.fromDir, i.fromGit, i.fromArchive} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of --from-dir, --from-git and --from-archive can be used")
+	}
+	if i.ref != "" && i.fromGit == "" {
+		return errors.New("--ref can only be used with --from-git")
+	}
+	if err := i.opts.Platform.Validate(); err != nil {
+		return errors.WithStack(err)
+	}
+	if sources == 0 {
+		if i.name != "" {
+			return errors.New("--name can only be used with custom installs")
+		}
+		if len(i.goVersions) == 0 {
+			return errors.New("missing the go version to install")
+		}
+		if i.settings.Concurrency < 0 {
+			return errors.New("--jobs must be greater than 0")
+		}
+		for _, goVersion := range i.goVersions {
+			if _, _, err := goinstalls.ParseVariantConstraint(goVersion); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	}
+	if len(i.goVersions) > 0 {
+		return errors.New("a go version cannot be passed along with a custom source")
+	}
+	if !i.opts.Platform.IsHost() {
+		return errors.New("custom installs are always built for the host, --os and --arch cannot be used")
+	}
+	if i.name != "" {
+		return errors.WithStack(goinstalls.ValidInstallName(i.name))
+	}
+	return nil
+}
+
+// Run implements Command.
+func (i Install) Run() error {
+	if src, ok := i.source(); ok {
+		return errors.WithStack(actions.InstallCustom(src, i.name, i.settings, i.opts))
+	}
+	return errors.WithStack(actions.Install(i.goVersions, i.settings, i.opts))
+}
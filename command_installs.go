@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/pkg/errors"
+)
+
+// Installs command prints a list of all the go installs.
+type Installs struct {
+}
+
+// Usage implements Command.
+func (i Installs) Usage() string {
+	return "the expected format is: goworkon installs"
+}
+
+// Validate implements Command.
+func (i Installs) Validate() error {
+	return nil
+}
+
+// Run implements Command.
+func (i Installs) Run() error {
+	return errors.WithStack(actions.Installs())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/pkg/errors"
+)
+
+// Prune command removes the go installs not used by any environment.
+type Prune struct {
+	keepPatches    int
+	keepUsedWithin string
+	dryRun         bool
+	settings       environment.Settings
+}
+
+// Usage implements Command.
+func (p Prune) Usage() string {
+	return "the expected format is: goworkon [--dry-run] [--keep-patches=N] [--keep-used-within=30d] prune"
+}
+
+// parseRetention parses durations as time.ParseDuration does with the
+// addition of days, ie: 30d.
+func parseRetention(retention string) (time.Duration, error) {
+	if retention == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(retention, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(retention, "d"))
+		if err != nil {
+			return 0, errors.Errorf("%q is not a valid amount of days", retention)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(retention)
+	return d, errors.WithStack(err)
+}
+
+// Validate implements Command.
+func (p Prune) Validate() error {
+	if p.keepPatches < 0 {
+		return errors.New("the amount of patches to keep cannot be negative")
+	}
+	if _, err := parseRetention(p.keepUsedWithin); err != nil {
+		return errors.Wrap(err, "invalid --keep-used-within")
+	}
+	return nil
+}
+
+// Run implements Command.
+func (p Prune) Run() error {
+	usedWithin, err := parseRetention(p.keepUsedWithin)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	policy := goinstalls.RetentionPolicy{
+		KeepPatches:    p.keepPatches,
+		KeepUsedWithin: usedWithin,
+	}
+	return errors.WithStack(actions.Prune(policy, p.dryRun, p.settings))
+}
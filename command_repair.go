@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
+	"github.com/pkg/errors"
+)
+
+// Repair command reinstalls a broken go install.
+type Repair struct {
+	goVersion string
+	settings  environment.Settings
+	opts      goinstalls.InstallOptions
+}
+
+// Usage implements Command.
+func (r Repair) Usage() string {
+	return "the expected format is: goworkon [Options] repair <version>"
+}
+
+// Validate implements Command.
+func (r Repair) Validate() error {
+	if r.goVersion == "" {
+		return errors.New("missing the go version to repair")
+	}
+	return nil
+}
+
+// Run implements Command.
+func (r Repair) Run() error {
+	return errors.WithStack(actions.Repair(r.goVersion, r.settings, r.opts))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
 	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/pkg/errors"
 )
@@ -11,6 +12,8 @@ import (
 type Update struct {
 	environmentName string
 	goVersion       string
+	settings        environment.Settings
+	opts            goinstalls.InstallOptions
 }
 
 // Usage implements Command.
@@ -23,13 +26,24 @@ func (u Update) Validate() error {
 	if u.environmentName == "" && u.goVersion == "" {
 		return errors.New("specify either a go version or an environment name")
 	}
+	if u.goVersion == "" {
+		return nil
+	}
+	if u.environmentName != "" {
+		// it can be a constraint or a custom install for a given environment.
+		if goinstalls.ValidInstallName(u.goVersion) == nil {
+			return nil
+		}
+		_, _, err := goinstalls.ParseVariantConstraint(u.goVersion)
+		return errors.WithStack(err)
+	}
 	v, err := goinstalls.VersionFromString(u.goVersion)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	// if only version is passed, version should be just a minor, this implies that
 	// we will update all x.y installs to the latest x.y
-	if u.environmentName == "" && v.Patch != 0 {
+	if !v.Language {
 		return errors.New("when passing only go version, ommit the patch version (major.minor.patch)")
 	}
 
@@ -38,16 +52,15 @@ func (u Update) Validate() error {
 
 // Run implements Command.
 func (u Update) Run() error {
+	if u.goVersion == "" {
+		return errors.WithStack(actions.UpdateToLatest(u.environmentName, u.settings, u.opts))
+	}
+	if u.environmentName != "" {
+		return errors.WithStack(actions.UpdateToVersion(u.environmentName, u.goVersion, u.settings, u.opts))
+	}
 	v, err := goinstalls.VersionFromString(u.goVersion)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-
-	if u.environmentName == "" {
-		return errors.WithStack(actions.UpdateAllTo(v))
-	}
-	if u.goVersion != "" {
-		return errors.WithStack(actions.UpdateToVersion(u.environmentName, v))
-	}
-	return errors.WithStack(actions.UpdateToLatest(u.environmentName))
+	return errors.WithStack(actions.UpdateAllTo(v, u.settings, u.opts))
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/pkg/errors"
+)
+
+// Verify command checks that go installs were not modified since they
+// were made.
+type Verify struct {
+	installName string
+}
+
+// Usage implements Command.
+func (v Verify) Usage() string {
+	return "the expected format is: goworkon verify [version]"
+}
+
+// Validate implements Command.
+func (v Verify) Validate() error {
+	return nil
+}
+
+// Run implements Command.
+func (v Verify) Run() error {
+	return errors.WithStack(actions.Verify(v.installName))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/perrito666/goworkon/actions"
+	"github.com/perrito666/goworkon/environment"
+	"github.com/pkg/errors"
+)
+
+// Versions command lists the installed go versions or those that can be
+// installed.
+type Versions struct {
+	remote   bool
+	all      bool
+	asJSON   bool
+	settings environment.Settings
+}
+
+// Usage implements Command.
+func (v Versions) Usage() string {
+	return "the expected format is: goworkon [--remote] [--all] [--json] versions"
+}
+
+// Validate implements Command.
+func (v Versions) Validate() error {
+	if v.all && !v.remote {
+		return errors.New("--all can only be used with --remote")
+	}
+	return nil
+}
+
+// Run implements Command.
+func (v Versions) Run() error {
+	return errors.WithStack(actions.Versions(v.remote, v.all, v.asJSON, v.settings))
+}
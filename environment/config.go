@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/juju/loggo"
@@ -23,12 +24,19 @@ type Config struct {
 	Name string `json:"name"`
 	// CompileSteps hold the commands to be run to compile this env main project.
 	CompileSteps []string `json:"compilesteps"`
-	// GoVersion holds the version of go this env should use.
+	// GoVersion holds the version of go this env should use, it is the
+	// name of its install.
 	GoVersion string `json:"goversion"`
+	// GoConstraint holds the version constraint GoVersion was resolved
+	// from, ie: ~1.21, update resolves it again.
+	GoConstraint string `json:"goconstraint,omitempty"`
 	// GlobalBin indicates if the $GOPATH/bin of this env will be added to PATH.
 	GlobalBin bool `json:"globalbin"`
 	// GoPath
 	GoPath string `json:"gopath"`
+	// Vars holds the variables exported when switching to this env, PATH
+	// holds entries added to the PATH instead, see VARSETTING.
+	Vars map[string]string `json:"vars,omitempty"`
 
 	// filePath holds the path for this config file.
 	filePath string
@@ -52,6 +60,30 @@ func maybeEnsureFolderExists(folder string) error {
 	return nil
 }
 
+// writeAtomically writes data into fileName through a temporary file so
+// readers never see it half written.
+func writeAtomically(fileName string, data []byte) error {
+	fp, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName))
+	if err != nil {
+		return errors.Wrapf(err, "creating temporary file for %q", fileName)
+	}
+	defer os.Remove(fp.Name())
+	defer fp.Close()
+	if _, err := fp.Write(data); err != nil {
+		return errors.Wrap(err, "writing marshaled data")
+	}
+	if err := fp.Chmod(0600); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := fp.Sync(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := fp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(fp.Name(), fileName))
+}
+
 // Save serializes and writes the Config in a file in the
 // passed folder.
 func (c *Config) Save(baseFolder string) error {
@@ -59,20 +91,11 @@ func (c *Config) Save(baseFolder string) error {
 		return errors.WithStack(err)
 	}
 	fileName := filepath.Join(baseFolder, fmt.Sprintf("%s.json", c.Name))
-	fp, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return errors.Wrapf(err, "opening config file %q for writing", fileName)
-	}
-	defer fp.Close()
 	marshaled, err := json.Marshal(c)
 	if err != nil {
 		return errors.Wrapf(err, "marshaling config for %q", c.Name)
 	}
-	_, err = fp.Write(marshaled)
-	if err != nil {
-		return errors.Wrap(err, "writing marshaled data")
-	}
-	return nil
+	return errors.Wrapf(writeAtomically(fileName, marshaled), "writing config file %q", fileName)
 }
 
 // LoadConfig will load Config files in the given location
@@ -106,6 +129,54 @@ func LoadConfig(baseFolder string) (map[string]Config, error) {
 	return allConfigs, nil
 }
 
+// VARSETTING is the prefix of the attributes that set Vars, ie: var.GOFLAGS.
+const VARSETTING = "var"
+
+var varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidVarName returns an error if name cannot be set in Vars, the
+// variables goworkon manages itself are not allowed.
+func ValidVarName(name string) error {
+	if !varNameRe.MatchString(name) {
+		return errors.Errorf("%q is not a valid environment variable name", name)
+	}
+	switch {
+	case name == "GOPATH", name == "PS1", name == "CDPATH", strings.HasPrefix(name, "GOWORKON_"):
+		return errors.Errorf("%q is set by goworkon, it cannot be set per environment", name)
+	}
+	return nil
+}
+
+// setVar sets the variable name to value in Vars, an empty value removes it.
+func (c *Config) setVar(name, value string) error {
+	if err := ValidVarName(name); err != nil {
+		return errors.WithStack(err)
+	}
+	if name == "PATH" && value != "" {
+		// the entries are added to PATH, empty ones would add the current
+		// folder.
+		for _, entry := range strings.Split(value, ":") {
+			if entry == "" || strings.ContainsAny(entry, "\n\r") {
+				return errors.Errorf("%q is not a valid list of PATH entries, separate folders with a single : and no line breaks", value)
+			}
+		}
+	}
+	vars := map[string]string{}
+	for k, v := range c.Vars {
+		vars[k] = v
+	}
+	if value == "" {
+		delete(vars, name)
+	} else {
+		vars[name] = value
+	}
+	c.Vars = nil
+	if len(vars) > 0 {
+		c.Vars = vars
+	}
+	return nil
+}
+
 // Set will set the value of <attribute> to <value> if attribute is a valid
 // member of Config.
 func (c Config) Set(attribute, value string) error {
@@ -113,6 +184,13 @@ func (c Config) Set(attribute, value string) error {
 		return errors.New("this config neds to be saved before Set can be used.")
 	}
 
+	if strings.HasPrefix(strings.ToLower(attribute), VARSETTING+".") {
+		if err := c.setVar(attribute[len(VARSETTING)+1:], value); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(c.Save(c.filePath))
+	}
+
 	switch strings.ToLower(attribute) {
 	case "globalbin":
 		if strings.ToLower(value) == "true" {
@@ -5,25 +5,226 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/pkg/errors"
 )
 
 // Settings holds general settings for goworkon.
 type Settings struct {
-	// Goroot is the path to a working goroot, it is
-	// required by the go compiler.
-	// TODO (perrito666) make this updateable by each new version.
+	// Goroot is the path to a working goroot, it is used to
+	// bootstrap source builds only when no install can do it.
 	Goroot string `json:"goroot"`
 	// Default is the default environment to set, this will behave
 	// a bit differently since its for general use.
 	Default string `json:"default"`
+	// FromSource indicates that go versions should be compiled from
+	// source instead of installed from the prebuilt archives.
+	FromSource bool `json:"fromsource"`
+	// Offline indicates that go versions can only be installed from
+	// the archives in the download cache.
+	Offline bool `json:"offline"`
+	// BuildMode determines how go is compiled from source, see
+	// goinstalls.ValidBuildMode.
+	BuildMode string `json:"buildmode"`
+	// Mirrors are base urls serving the go release feed and files, they
+	// are tried in order before the official ones, see ActiveMirrors.
+	Mirrors []string `json:"mirrors"`
+	// CABundle is the path to a PEM file with extra certificates to trust
+	// when downloading.
+	CABundle string `json:"cabundle"`
+	// Proxy is the url of the proxy used to download, the usual proxy
+	// environment variables are used if empty.
+	Proxy string `json:"proxy"`
+	// Timeout limits connecting to the download servers, ie: 30s.
+	Timeout string `json:"timeout"`
+	// GoProxy are module proxies, as in GOPROXY, go is installed from the
+	// toolchain modules they serve instead of the release archives if set,
+	// see ActiveGoProxy.
+	GoProxy []string `json:"goproxy,omitempty"`
+	// GoSumDB is the checksum database toolchain modules are verified
+	// against, as in GOSUMDB, its records are fetched from it directly.
+	// off uses the .ziphash served by the proxy, only if TrustGoProxy.
+	GoSumDB string `json:"gosumdb,omitempty"`
+	// TrustGoProxy allows installing toolchain modules with GoSumDB off,
+	// trusting the proxies to serve them untampered.
+	TrustGoProxy bool `json:"trustgoproxy,omitempty"`
+	// Concurrency is the amount of go versions installed at the same
+	// time when several are requested, see InstallJobs.
+	Concurrency int `json:"concurrency,omitempty"`
+	// GoEnv holds the defaults written into the go.env of the installs of
+	// go versions that read it, see GoEnvAttribute.
+	GoEnv goinstalls.GoEnv `json:"goenv,omitempty"`
+	// Variants holds the build variants that can be installed, by name,
+	// see VariantAttribute.
+	Variants map[string]goinstalls.Variant `json:"variants,omitempty"`
 
 	// filePath holds the path for this settings file.
 	filePath string
 }
 
+// MIRRORSENVVAR is the environment variable that, when set, overrides
+// the mirrors in the settings with a comma separated list of urls.
+const MIRRORSENVVAR = "GOWORKON_MIRRORS"
+
+// GOPROXYENVVAR is the environment variable that, when set, overrides the
+// module proxies in the settings with a comma separated list of urls.
+const GOPROXYENVVAR = "GOWORKON_GOPROXY"
+
+// splitList returns the non empty elements of a comma separated list.
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ActiveMirrors returns the mirrors to use, those in MIRRORSENVVAR if it
+// is set or the ones in the settings otherwise.
+func (s Settings) ActiveMirrors() []string {
+	if mirrors, ok := os.LookupEnv(MIRRORSENVVAR); ok {
+		return splitList(mirrors)
+	}
+	return s.Mirrors
+}
+
+// ActiveGoProxy returns the module proxies to use, those in GOPROXYENVVAR
+// if it is set or the ones in the settings otherwise.
+func (s Settings) ActiveGoProxy() []string {
+	if proxies, ok := os.LookupEnv(GOPROXYENVVAR); ok {
+		return splitList(proxies)
+	}
+	return s.GoProxy
+}
+
+// DEFAULTCONCURRENCY is the amount of go versions installed at the same
+// time when Concurrency is not set.
+const DEFAULTCONCURRENCY = 4
+
+// InstallJobs returns the amount of go versions to install at the same
+// time.
+func (s Settings) InstallJobs() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return DEFAULTCONCURRENCY
+}
+
+// GOENVSETTING is the prefix of the attributes that set GoEnv, either
+// goenv.NAME for all installs or goenv.<version or install name>.NAME.
+const GOENVSETTING = "goenv"
+
+// GoEnvAttribute returns the install name or language version, empty for
+// all installs, and the variable name that attribute sets in GoEnv, ok is
+// false if it does not set one.
+func GoEnvAttribute(attribute string) (version, name string, ok bool) {
+	parts := strings.Split(attribute, ".")
+	if len(parts) < 2 || strings.ToLower(parts[0]) != GOENVSETTING {
+		return "", "", false
+	}
+	return strings.Join(parts[1:len(parts)-1], "."), parts[len(parts)-1], true
+}
+
+// setGoEnv sets name to value in GoEnv for the installs of version, all if
+// empty, an empty value removes it.
+func (s *Settings) setGoEnv(version, name, value string) error {
+	if err := goinstalls.ValidGoEnvName(name); err != nil {
+		return errors.WithStack(err)
+	}
+	if version != "" {
+		if _, err := goinstalls.VersionFromString(version); err != nil && goinstalls.ValidInstallName(version) != nil {
+			return errors.Errorf("%q is neither a go version nor an install name", version)
+		}
+	}
+	s.GoEnv = s.GoEnv.Set(version, name, value)
+	return nil
+}
+
+// VARIANTSETTING is the prefix of the attributes that set Variants, either
+// variant.<name>.env.NAME for a build variable or variant.<name>.patches
+// for a comma separated list of patch files.
+const VARIANTSETTING = "variant"
+
+// VariantAttribute returns the build variant and what of it attribute
+// sets, ok is false if it does not set one.
+func VariantAttribute(attribute string) (variant, field string, ok bool) {
+	parts := strings.SplitN(attribute, ".", 3)
+	if len(parts) < 3 || strings.ToLower(parts[0]) != VARIANTSETTING {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// setVariant sets field of the build variant called name to value, the
+// variant is removed when it has neither variables nor patches left.
+func (s *Settings) setVariant(name, field, value string) error {
+	if err := goinstalls.ValidVariantName(name); err != nil {
+		return errors.WithStack(err)
+	}
+	variant := s.Variants[name]
+	switch {
+	case strings.ToLower(field) == "patches":
+		variant.Patches = nil
+		for _, patch := range splitList(value) {
+			abs, err := filepath.Abs(patch)
+			if err != nil {
+				return errors.Wrapf(err, "finding patch %q", patch)
+			}
+			if _, err := os.Stat(abs); err != nil {
+				return errors.Wrapf(err, "finding patch %q", patch)
+			}
+			variant.Patches = append(variant.Patches, abs)
+		}
+	case strings.HasPrefix(strings.ToLower(field), "env."):
+		envName := field[len("env."):]
+		if err := goinstalls.ValidGoEnvName(envName); err != nil {
+			return errors.WithStack(err)
+		}
+		env := map[string]string{}
+		for k, v := range variant.Env {
+			env[k] = v
+		}
+		if value == "" {
+			delete(env, envName)
+		} else {
+			env[envName] = value
+		}
+		variant.Env = nil
+		if len(env) > 0 {
+			variant.Env = env
+		}
+	default:
+		return errors.Errorf("%q is not a build variant setting, use env.NAME or patches", field)
+	}
+	variants := map[string]goinstalls.Variant{}
+	for k, v := range s.Variants {
+		variants[k] = v
+	}
+	delete(variants, name)
+	if len(variant.Env) > 0 || len(variant.Patches) > 0 {
+		variants[name] = variant
+	}
+	s.Variants = variants
+	return nil
+}
+
+// Variant returns the build variant called name.
+func (s Settings) Variant(name string) (goinstalls.Variant, error) {
+	variant, ok := s.Variants[name]
+	if !ok {
+		return goinstalls.Variant{}, errors.Errorf("build variant %q is not defined, see: goworkon set %s.%s.env.NAME value",
+			name, VARIANTSETTING, name)
+	}
+	variant.Name = name
+	return variant, nil
+}
+
 // Save serializes and writes the Settings in a file in the
 // passed folder.
 func (s Settings) Save(baseFolder string) error {
@@ -31,20 +232,11 @@ func (s Settings) Save(baseFolder string) error {
 		return errors.WithStack(err)
 	}
 	fileName := filepath.Join(baseFolder, SETTINGSFILE)
-	fp, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return errors.Wrapf(err, "opening settings file %q for writing", fileName)
-	}
-	defer fp.Close()
 	marshaled, err := json.Marshal(s)
 	if err != nil {
 		return errors.Wrap(err, "marshaling settings")
 	}
-	_, err = fp.Write(marshaled)
-	if err != nil {
-		return errors.Wrap(err, "writing marshaled settings")
-	}
-	return nil
+	return errors.Wrapf(writeAtomically(fileName, marshaled), "writing settings file %q", fileName)
 }
 
 // Set will set the value of <attribute> to <value> if attribute is a valid
@@ -54,24 +246,97 @@ func (s Settings) Set(attribute, value string) error {
 		return errors.New("these settings neds to be saved before Set can be used")
 	}
 
+	if version, name, ok := GoEnvAttribute(attribute); ok {
+		if err := s.setGoEnv(version, name, value); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(s.Save(s.filePath))
+	}
+
+	if name, field, ok := VariantAttribute(attribute); ok {
+		if err := s.setVariant(name, field, value); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(s.Save(s.filePath))
+	}
+
 	switch strings.ToLower(attribute) {
 	case "goroot":
 		s.Goroot = value
 	case "default":
 		s.Default = value
+	case "fromsource":
+		fromSource, err := parseBoolSetting(attribute, value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.FromSource = fromSource
+	case "offline":
+		offline, err := parseBoolSetting(attribute, value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.Offline = offline
+	case "buildmode":
+		if err := goinstalls.ValidBuildMode(value); err != nil {
+			return errors.WithStack(err)
+		}
+		s.BuildMode = value
+	case "mirrors":
+		s.Mirrors = splitList(value)
+	case "cabundle":
+		s.CABundle = value
+	case "proxy":
+		s.Proxy = value
+	case "timeout":
+		if value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return errors.Wrapf(err, "%q is not a valid timeout", value)
+			}
+		}
+		s.Timeout = value
+	case "goproxy":
+		s.GoProxy = splitList(value)
+	case "gosumdb":
+		s.GoSumDB = value
+	case "trustgoproxy":
+		trust, err := parseBoolSetting(attribute, value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.TrustGoProxy = trust
+	case "concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return errors.Errorf("%q is not a valid concurrency, use a number greater than 0", value)
+		}
+		s.Concurrency = n
 	default:
 		return errors.Errorf("%q is not a valid setting", attribute)
 	}
 	return errors.WithStack(s.Save(s.filePath))
 }
 
+// parseBoolSetting parses value as the boolean setting attribute, an empty
+// value blanks it to false.
+func parseBoolSetting(attribute, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Errorf("%q is not a valid value for %s, use true or false", value, attribute)
+	}
+	return b, nil
+}
+
 // LoadSettings will load Settings files in the given location
 func LoadSettings(baseFolder string) (Settings, error) {
 	settingsFile := filepath.Join(baseFolder, SETTINGSFILE)
 	logger.Debugf("loading settings from %q", settingsFile)
 	_, err := os.Stat(settingsFile)
 	if os.IsNotExist(err) {
-		return Settings{}, nil
+		return Settings{filePath: baseFolder}, nil
 	}
 	if err != nil {
 		return Settings{}, errors.Wrap(err, "reading settings")
@@ -0,0 +1,91 @@
+package goinstalls
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SOURCEADOPTED is the InstallInfo.Source of go trees installed by other
+// means and registered with Adopt, they are never modified or removed.
+const SOURCEADOPTED = "adopted"
+
+// Adopted returns true for installs registered with Adopt.
+func (i InstallInfo) Adopted() bool {
+	return i.Source == SOURCEADOPTED
+}
+
+// GorootVersion returns the go release in goroot, as reported by its
+// go version.
+func GorootVersion(goroot string) (Version, error) {
+	goBinary := filepath.Join(goroot, "bin", "go")
+	cmd := exec.Command(goBinary, "version")
+	// the tree must report its own version, not the one of the current goroot.
+	cmd.Env = append(os.Environ(), "GOROOT="+goroot, "GOTOOLCHAIN=local")
+	out, err := cmd.Output()
+	if err != nil {
+		return Version{}, errors.Wrapf(err, "running %s version", goBinary)
+	}
+	// ie: go version go1.21.3 linux/amd64
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[0] != "go" || fields[1] != "version" {
+		return Version{}, errors.Errorf("unexpected output of %s version: %q", goBinary, string(out))
+	}
+	v, err := VersionFromName(fields[2])
+	if err != nil {
+		return Version{}, errors.Wrapf(err, "%q is not a go release, build it with: goworkon --from-dir=%s install",
+			goroot, goroot)
+	}
+	return v, nil
+}
+
+// Adopt registers the go tree in goroot as the install of its version in
+// installsFolder, the install links to goroot instead of copying it. Trees
+// with an usable install of their version already are refused.
+func Adopt(goroot, installsFolder string, opts InstallOptions) (Version, error) {
+	goroot, err := filepath.Abs(goroot)
+	if err != nil {
+		return Version{}, errors.WithStack(err)
+	}
+	if goroot, err = filepath.EvalSymlinks(goroot); err != nil {
+		return Version{}, errors.Wrapf(err, "resolving %q", goroot)
+	}
+	if within(filepath.Clean(installsFolder), goroot) {
+		return Version{}, errors.Errorf("%q is already managed by goworkon", goroot)
+	}
+	v, err := GorootVersion(goroot)
+	if err != nil {
+		return Version{}, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(installsFolder, 0700); err != nil {
+		return Version{}, errors.Wrapf(err, "creating installs folder %q", installsFolder)
+	}
+	installPath := filepath.Join(installsFolder, v.String())
+	unlock, err := lockInstall(v.String(), opts)
+	if err != nil {
+		return Version{}, errors.WithStack(err)
+	}
+	defer unlock()
+	if CheckInstall(installPath) == "" {
+		return Version{}, errors.Errorf("go %q is already installed in %q", v.String(), installPath)
+	}
+	workPath := hiddenSibling(installPath, partialInstallSuffix)
+	if err := os.RemoveAll(workPath); err != nil {
+		return Version{}, errors.Wrapf(err, "removing %q", workPath)
+	}
+	if err := os.MkdirAll(workPath, 0755); err != nil {
+		return Version{}, errors.Wrapf(err, "creating %q", workPath)
+	}
+	if err := os.Symlink(goroot, filepath.Join(workPath, "go")); err != nil {
+		return Version{}, errors.Wrapf(err, "linking %q", goroot)
+	}
+	info := InstallInfo{
+		Version: v.String(),
+		Source:  SOURCEADOPTED,
+		Origin:  goroot,
+	}
+	return v, errors.WithStack(commitInstall(workPath, installPath, info, Manifest{}, GoEnv{}))
+}
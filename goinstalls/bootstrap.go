@@ -0,0 +1,103 @@
+package goinstalls
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// BootstrapRequirement returns the oldest go release that can compile v,
+// false is returned for versions that are built with a C toolchain.
+func BootstrapRequirement(v Version) (Version, bool) {
+	switch {
+	case v.Major == 1 && v.Minor < 5:
+		return Version{}, false
+	case v.Major == 1 && v.Minor < 20:
+		return Version{Major: 1, Minor: 4}, true
+	case v.Major == 1 && v.Minor < 22:
+		return Version{Major: 1, Minor: 17, Patch: 13}, true
+	}
+	// since 1.22, 1.N requires the 1.M point release that fixes the
+	// bootstrap, with M being N-2 rounded down to an even number.
+	minor := v.Minor - 2
+	minor -= minor % 2
+	return Version{Major: v.Major, Minor: minor, Patch: 6}, true
+}
+
+// canBootstrap returns true if candidate is a release that can compile v,
+// releases newer than v can compile it too.
+func canBootstrap(candidate, v Version) bool {
+	required, needed := BootstrapRequirement(v)
+	if !needed {
+		return true
+	}
+	return candidate.IsRelease() && candidate.Compare(required) >= 0
+}
+
+// FindBootstrap returns the GOROOT of the oldest usable install in
+// installsFolder that can compile v, false is returned if there is none.
+func FindBootstrap(v Version, installsFolder string) (string, bool, error) {
+	installed, err := InstalledAvailableVersions(installsFolder)
+	if err != nil {
+		return "", false, errors.WithStack(err)
+	}
+	found := false
+	var oldest Version
+	for _, candidate := range installed {
+		if !canBootstrap(candidate, v) {
+			continue
+		}
+		if !found || oldest.IsNewerThan(candidate) {
+			oldest = candidate
+			found = true
+		}
+	}
+	if !found {
+		return "", false, nil
+	}
+	return filepath.Join(installsFolder, oldest.String(), "go"), true, nil
+}
+
+// Bootstrap returns the GOROOT to be used to compile v, it picks the oldest
+// install that can compile v, installing the prebuilt version of the required
+// release from releases if there is none, opts.Goroot is returned only if
+// neither is possible.
+func Bootstrap(v Version, releases map[Version]Release, installsFolder string, opts InstallOptions) (string, error) {
+	required, needed := BootstrapRequirement(v)
+	if !needed {
+		return "", nil
+	}
+	goroot, ok, err := FindBootstrap(v, installsFolder)
+	if err != nil {
+		return "", errors.Wrapf(err, "finding an install to bootstrap go %q", v.String())
+	}
+	if ok {
+		logger.Infof("bootstrapping go %q with %q", v.String(), goroot)
+		return goroot, nil
+	}
+
+	for candidate, release := range NewestPatches(releases) {
+		if !candidate.SameVersion(required) || !canBootstrap(candidate, v) {
+			continue
+		}
+		logger.Infof("installing go %q to bootstrap go %q", candidate.String(), v.String())
+		binaryOpts := opts
+		binaryOpts.FromSource = false
+		binaryOpts.Platform = HostPlatform()
+		binaryOpts.Variant = Variant{}
+		err := InstallVersion(candidate, release, installsFolder, binaryOpts)
+		if err == nil {
+			return filepath.Join(installsFolder, candidate.String(), "go"), nil
+		}
+		if opts.Goroot == "" {
+			return "", errors.Wrapf(err, "installing go %q to bootstrap go %q", candidate.String(), v.String())
+		}
+		logger.Warningf("cannot install go %q to bootstrap go %q: %v", candidate.String(), v.String(), err)
+	}
+	if opts.Goroot == "" {
+		return "", errors.Errorf("go %q requires go %q or newer to be built, none is available, "+
+			"set a bootstrap goroot with: goworkon set goroot <path>", v.String(), required.String())
+	}
+	logger.Infof("bootstrapping go %q with the configured goroot %q", v.String(), opts.Goroot)
+	return opts.Goroot, nil
+}
@@ -0,0 +1,172 @@
+package goinstalls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/perrito666/goworkon/progress"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BUILDMAKE builds go with make.bash, without running the tests.
+	BUILDMAKE = "make"
+	// BUILDALL builds go with all.bash, a failing test fails the install.
+	BUILDALL = "all"
+	// BUILDALLALLOWFAIL builds go with make.bash and then runs the tests,
+	// failing tests are reported but the install is kept.
+	BUILDALLALLOWFAIL = "all-allowfail"
+
+	// BUILDLOGFILE is the name of the file, in the install folder, where
+	// the output of the build is written.
+	BUILDLOGFILE = "build.log"
+	// buildLogTail is the amount of lines of the log shown on failure.
+	buildLogTail = 20
+)
+
+// ValidBuildMode returns an error if mode is not a known build mode, the
+// empty mode is valid and means BUILDMAKE.
+func ValidBuildMode(mode string) error {
+	switch mode {
+	case "", BUILDMAKE, BUILDALL, BUILDALLALLOWFAIL:
+		return nil
+	}
+	return errors.Errorf("%q is not a valid build mode, use %q, %q or %q",
+		mode, BUILDMAKE, BUILDALL, BUILDALLALLOWFAIL)
+}
+
+// logTail returns the last n lines of the file in logPath.
+func logTail(logPath string, n int) string {
+	fp, err := os.Open(logPath)
+	if err != nil {
+		return ""
+	}
+	defer fp.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runBuildScript runs the given script from srcPath, with env as its
+// environment, with its output going to log and reported, line by line, to
+// task.
+func runBuildScript(srcPath, script string, env []string, log io.Writer, task *progress.Task, args ...string) error {
+	fmt.Fprintf(log, "### running %s %s\n", script, strings.Join(args, " "))
+	cmd := exec.Command(filepath.Join(srcPath, script), args...)
+	cmd.Dir = srcPath
+	cmd.Env = env
+	output := io.MultiWriter(log, task.Lines())
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return errors.Wrapf(cmd.Run(), "running %s", script)
+}
+
+// build compiles the go source in targetPath according to mode with env as
+// the environment of the build scripts, its output is logged into
+// BUILDLOGFILE and reported to task.
+func build(targetPath, mode string, env []string, task *progress.Task) error {
+	logPath := filepath.Join(targetPath, BUILDLOGFILE)
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "creating build log %q", logPath)
+	}
+	defer log.Close()
+
+	srcPath := filepath.Join(targetPath, "go", "src")
+	switch mode {
+	case BUILDALL:
+		err = runBuildScript(srcPath, "all.bash", env, log, task)
+	case BUILDALLALLOWFAIL:
+		err = runBuildScript(srcPath, "make.bash", env, log, task)
+		if err == nil {
+			if testErr := runBuildScript(srcPath, "run.bash", env, log, task, "--no-rebuild"); testErr != nil {
+				logger.Warningf("go tests failed, keeping the build anyway, see %q", logPath)
+			}
+		}
+	default:
+		err = runBuildScript(srcPath, "make.bash", env, log, task)
+	}
+	if err != nil {
+		return errors.Errorf("building go failed (%v), the full log is in %q, its last lines are:\n%s",
+			err, logPath, logTail(logPath, buildLogTail))
+	}
+	return nil
+}
+
+// installFromSource downloads the given source file, extracts it into
+// targetPath, applies the patches of opts.Variant and compiles it using
+// opts.Goroot for bootstrap, the result is meant to be moved to
+// installPath, how it was made is recorded in m.
+func installFromSource(v Version, file ReleaseFile, targetPath, installPath string, opts InstallOptions, m *Manifest) error {
+	if err := checkCanBuild(v, opts); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := downloadAndExtract(v, file, targetPath, opts, m); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := applyPatches(filepath.Join(targetPath, "go"), opts.Variant); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(buildTree(WithVariant(v.String(), opts.Variant.Name), targetPath, installPath, opts, m))
+}
+
+// checkCanBuild returns an error if opts are not enough to build go v.
+func checkCanBuild(v Version, opts InstallOptions) error {
+	if _, needed := BootstrapRequirement(v); needed && opts.Goroot == "" {
+		return errors.Errorf("building go %q from source requires a bootstrap goroot", v.String())
+	}
+	return errors.WithStack(ValidBuildMode(opts.BuildMode))
+}
+
+// withEnv returns env with the given variables, in KEY=value form, set
+// replacing any previous value.
+func withEnv(env []string, vars ...string) []string {
+	result := make([]string, 0, len(env)+len(vars))
+	for _, kv := range env {
+		replaced := false
+		for _, v := range vars {
+			if strings.HasPrefix(kv, v[:strings.Index(v, "=")+1]) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, kv)
+		}
+	}
+	return append(result, vars...)
+}
+
+// buildTree compiles the go tree in targetPath using opts.Goroot for
+// bootstrap and the environment of opts.Variant, the result is meant to be
+// moved to installPath, progress is reported for subject and the bootstrap
+// and build time are recorded in m.
+// The process environment and working folder are left untouched, so
+// several trees can be built at the same time.
+func buildTree(subject, targetPath, installPath string, opts InstallOptions, m *Manifest) error {
+	env := withEnv(os.Environ(),
+		"GOROOT_BOOTSTRAP="+opts.Goroot,
+		// the build happens away from where it will live.
+		"GOROOT_FINAL="+filepath.Join(installPath, "go"))
+	env = withEnv(env, opts.Variant.environ()...)
+
+	task := progress.Start(opts.Progress, subject, progress.STAGEBUILD, 0)
+	started := time.Now()
+	err := build(targetPath, opts.BuildMode, env, task)
+	m.Bootstrap = opts.Goroot
+	m.BuildSeconds = time.Since(started).Seconds()
+	task.Done(err)
+	return errors.WithStack(err)
+}
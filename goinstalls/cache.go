@@ -0,0 +1,238 @@
+package goinstalls
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/perrito666/goworkon/lockfile"
+	"github.com/perrito666/goworkon/progress"
+	"github.com/pkg/errors"
+)
+
+const (
+	// partialSuffix is appended to the name of archives being downloaded
+	// into the cache.
+	partialSuffix = ".part"
+	// cachedChecksumSuffix is appended to the name of a cached archive to
+	// obtain the file holding its SHA-256.
+	cachedChecksumSuffix = ".sha256"
+	// cachedSourceSuffix is appended to the name of a cached archive to
+	// obtain the file holding the url it was downloaded from.
+	cachedSourceSuffix = ".url"
+	// cachedLockSuffix is appended to the name of a cached archive to
+	// obtain the lock held while it is looked up and downloaded.
+	cachedLockSuffix = ".lock"
+)
+
+// lockCached takes the lock of the archive in cachePath, so concurrent
+// installs that need it do not download it into the same partial file,
+// the returned function releases it.
+func lockCached(cachePath string) (func(), error) {
+	lock, err := lockfile.Acquire(cachePath+cachedLockSuffix, installLockTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "locking %q in the download cache", filepath.Base(cachePath))
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}, nil
+}
+
+// fetch downloads url into the partial file, if partial already holds
+// part of the file the download is resumed from there, progress is
+// reported to task.
+func fetch(url, partial string, task *progress.Task) error {
+	fp, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "opening %q", partial)
+	}
+	defer fp.Close()
+	offset, err := fp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrapf(err, "finding the size of %q", partial)
+	}
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	response, err := client().Do(request)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(response.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return errors.Errorf("downloading %q: unexpected range %q", url, response.Header.Get("Content-Range"))
+		}
+		logger.Debugf("resuming download of %q from byte %d", url, offset)
+		task.Resume(offset)
+	case http.StatusOK:
+		// the server ignored the range, start over.
+		if err := fp.Truncate(0); err != nil {
+			return errors.Wrapf(err, "truncating %q", partial)
+		}
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrapf(err, "rewinding %q", partial)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// we already have the whole file.
+		return nil
+	default:
+		return errors.Errorf("downloading %q: %s", url, response.Status)
+	}
+	if response.ContentLength > 0 {
+		task.SetTotal(task.Resumed() + response.ContentLength)
+	}
+	if _, err := io.Copy(fp, task.Reader(response.Body)); err != nil {
+		return errors.Wrapf(err, "downloading %q", url)
+	}
+	return nil
+}
+
+// fetchFromSources downloads file of go v into partial and verifies it
+// against expected, each source is tried in order until one succeeds and
+// the url it was downloaded from is returned.
+func fetchFromSources(v Version, file ReleaseFile, partial, expected string, opts InstallOptions) (string, error) {
+	failures := []string{}
+	for _, source := range releaseSources() {
+		url := source.FileURL(file)
+		task := progress.Start(opts.Progress, InstallName(v, opts.Platform), progress.STAGEDOWNLOAD, file.Size)
+		err := fetch(url, partial, task)
+		if err == nil {
+			if err = verifyChecksum(partial, expected); err != nil {
+				// there is no point in resuming a corrupt download.
+				os.Remove(partial)
+			}
+		}
+		task.Done(err)
+		if err == nil {
+			return url, nil
+		}
+		logger.Warningf("cannot download %q: %v", url, err)
+		failures = append(failures, err.Error())
+	}
+	return "", errors.Errorf("refusing to install %q, no source could provide it: %s",
+		file.Filename, strings.Join(failures, "; "))
+}
+
+// download returns the path to the given file of go v in the download
+// cache and the url it was downloaded from, if it is not cached yet it is
+// downloaded and verified against expected first.
+func download(v Version, file ReleaseFile, expected string, opts InstallOptions) (string, string, error) {
+	if opts.CacheDir == "" {
+		return "", "", errors.New("no download cache folder specified")
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0700); err != nil {
+		return "", "", errors.Wrapf(err, "creating download cache %q", opts.CacheDir)
+	}
+	archivePath := filepath.Join(opts.CacheDir, file.Filename)
+	// the cache is looked up with the lock held, another install might
+	// have just downloaded the archive.
+	unlock, err := lockCached(archivePath)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	defer unlock()
+	sourceFile := archivePath + cachedSourceSuffix
+	if _, err := os.Stat(archivePath); err == nil {
+		err := verifyChecksum(archivePath, expected)
+		if err == nil {
+			logger.Debugf("using cached %q", archivePath)
+			// archives cached before urls were recorded have none.
+			sourceURL, _ := ioutil.ReadFile(sourceFile)
+			return archivePath, strings.TrimSpace(string(sourceURL)), nil
+		}
+		logger.Warningf("discarding cached %q: %v", archivePath, err)
+		if err := os.Remove(archivePath); err != nil {
+			return "", "", errors.Wrapf(err, "removing corrupt %q", archivePath)
+		}
+	}
+	if opts.Offline {
+		return "", "", errors.Errorf("%q is not in the download cache and we are offline", file.Filename)
+	}
+
+	partial := archivePath + partialSuffix
+	sourceURL, err := fetchFromSources(v, file, partial, expected, opts)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if err := os.Rename(partial, archivePath); err != nil {
+		return "", "", errors.Wrapf(err, "moving %q into the download cache", file.Filename)
+	}
+	checksumFile := archivePath + cachedChecksumSuffix
+	if err := ioutil.WriteFile(checksumFile, []byte(expected+"\n"), 0600); err != nil {
+		return "", "", errors.Wrapf(err, "writing %q", checksumFile)
+	}
+	if err := ioutil.WriteFile(sourceFile, []byte(sourceURL+"\n"), 0600); err != nil {
+		return "", "", errors.Wrapf(err, "writing %q", sourceFile)
+	}
+	return archivePath, sourceURL, nil
+}
+
+var cachedArchiveRe = regexp.MustCompile(`^(go.+?)\.(?:(src)|([a-z0-9]+)-([a-z0-9]+))\.tar\.gz$`)
+
+// CachedReleases returns the releases that can be installed from the
+// archives in cacheDir, only archives with a SHA-256 next to them, as
+// <archive>.sha256, are taken into account.
+func CachedReleases(cacheDir string) (map[Version]Release, error) {
+	entries, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return map[Version]Release{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading download cache %q", cacheDir)
+	}
+	releases := map[Version]Release{}
+	for _, entry := range entries {
+		parts := cachedArchiveRe.FindStringSubmatch(entry.Name())
+		if parts == nil || !entry.Mode().IsRegular() {
+			continue
+		}
+		v, err := VersionFromName(parts[1])
+		if err != nil {
+			logger.Debugf("skipping cached %q: %v", entry.Name(), err)
+			continue
+		}
+		checksumFile := filepath.Join(cacheDir, entry.Name()+cachedChecksumSuffix)
+		contents, err := ioutil.ReadFile(checksumFile)
+		if err != nil {
+			logger.Debugf("skipping cached %q, it has no checksum", entry.Name())
+			continue
+		}
+		sum, err := parseChecksum(string(contents))
+		if err != nil {
+			logger.Debugf("skipping cached %q: %v", entry.Name(), err)
+			continue
+		}
+		file := ReleaseFile{
+			Filename: entry.Name(),
+			OS:       parts[3],
+			Arch:     parts[4],
+			Version:  parts[1],
+			SHA256:   sum,
+			Size:     entry.Size(),
+			Kind:     KINDARCHIVE,
+		}
+		if parts[2] != "" {
+			file.Kind = KINDSOURCE
+		}
+		release := releases[v]
+		release.Version = parts[1]
+		release.Stable = v.IsRelease()
+		release.Files = append(release.Files, file)
+		releases[v] = release
+	}
+	return releases, nil
+}
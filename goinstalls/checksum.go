@@ -0,0 +1,91 @@
+package goinstalls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CHECKSUMFILE is the name of the file, stored in the install folder, that
+// holds the verified SHA-256 of the archive the install came from.
+const CHECKSUMFILE = "SHA256SUM"
+
+// parseChecksum returns the hex encoded SHA-256 at the start of raw, which
+// can be either just the hash or a sha256sum formatted line.
+func parseChecksum(raw string) (string, error) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return "", errors.New("empty checksum")
+	}
+	sum := strings.ToLower(fields[0])
+	decoded, err := hex.DecodeString(sum)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", errors.Errorf("%q is not a valid SHA-256", fields[0])
+	}
+	return sum, nil
+}
+
+// hashFile returns the hex encoded SHA-256 of the file in the given path.
+func hashFile(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "opening %q to hash it", path)
+	}
+	defer fp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", errors.Wrapf(err, "hashing %q", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum returns an error if the file in path does not hash
+// to the expected sum.
+func verifyChecksum(path, expected string) error {
+	sum, err := hashFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if sum != expected {
+		return errors.Errorf("checksum mismatch for %q: expected %s got %s",
+			filepath.Base(path), expected, sum)
+	}
+	return nil
+}
+
+// recordChecksum writes the sum of archive into the install folder in the
+// sha256sum format.
+func recordChecksum(installPath, archive, sum string) error {
+	fileName := filepath.Join(installPath, CHECKSUMFILE)
+	line := fmt.Sprintf("%s  %s\n", sum, archive)
+	if err := ioutil.WriteFile(fileName, []byte(line), 0600); err != nil {
+		return errors.Wrapf(err, "recording checksum in %q", fileName)
+	}
+	return nil
+}
+
+// RecordedChecksum returns the verified SHA-256 and archive name recorded
+// when the install in installPath was created.
+func RecordedChecksum(installPath string) (string, string, error) {
+	fileName := filepath.Join(installPath, CHECKSUMFILE)
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "reading recorded checksum %q", fileName)
+	}
+	fields := strings.Fields(string(contents))
+	if len(fields) != 2 {
+		return "", "", errors.Errorf("%q is not a valid checksum file", fileName)
+	}
+	sum, err := parseChecksum(fields[0])
+	if err != nil {
+		return "", "", errors.Wrapf(err, "parsing %q", fileName)
+	}
+	return sum, fields[1], nil
+}
@@ -0,0 +1,178 @@
+package goinstalls
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CHANNELLATEST selects the newest release, pre-releases included.
+	CHANNELLATEST = "latest"
+	// CHANNELSTABLE selects the newest stable release.
+	CHANNELSTABLE = "stable"
+	// CHANNELOLDSTABLE selects the newest stable release of the minor
+	// before the one of CHANNELSTABLE, the other supported go.
+	CHANNELOLDSTABLE = "oldstable"
+)
+
+// bound is a single comparison of a Constraint, ie: >=1.20.
+type bound struct {
+	op      string
+	version Version
+}
+
+// matches returns true if v satisfies b.
+func (b bound) matches(v Version) bool {
+	c := v.Compare(b.version)
+	switch b.op {
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case "<":
+		return c < 0
+	}
+	return c == 0
+}
+
+// Constraint selects the go releases that satisfy it, see ParseConstraint.
+type Constraint struct {
+	raw     string
+	channel string
+	bounds  []bound
+	// pre is true if pre-releases can satisfy the constraint.
+	pre bool
+}
+
+var (
+	boundRe = regexp.MustCompile(`^(>=|<=|>|<|=)?(.+)$`)
+	tildeRe = regexp.MustCompile(`^~(.+)$`)
+	xRe     = regexp.MustCompile(`^(\d+\.\d+)\.[xX*]$`)
+)
+
+// minorBounds returns the bounds of the releases of the minor of v that
+// are not older than v.
+func minorBounds(v Version) []bound {
+	next := Version{Major: v.Major, Minor: v.Minor + 1, Language: true}
+	return []bound{{op: ">=", version: v}, {op: "<", version: next}}
+}
+
+// languageBounds returns the bounds of the comparison op against the
+// language version v, which sorts before every release of its minor, so
+// <=1.21 and >1.21 are compared against 1.22 to take in or leave out the
+// releases of 1.21. A bare language version is the newest release of its
+// minor.
+func languageBounds(op string, v Version) []bound {
+	next := Version{Major: v.Major, Minor: v.Minor + 1, Language: true}
+	switch op {
+	case "", "=":
+		return minorBounds(v)
+	case "<=":
+		return []bound{{op: "<", version: next}}
+	case ">":
+		return []bound{{op: ">=", version: next}}
+	}
+	return []bound{{op: op, version: v}}
+}
+
+// ParseConstraint parses a version constraint, it can be a channel
+// (CHANNELLATEST, CHANNELSTABLE or CHANNELOLDSTABLE), a version (1.21.3 or
+// 1.21rc2), a language version or its equivalents (1.21, ~1.21 and 1.21.x)
+// which select the newest release of the minor, ~1.21.3 for the newest
+// release of the minor not older than 1.21.3 or space separated comparisons
+// that must all be satisfied (>=1.20 <1.22).
+// Pre-releases are only selected by CHANNELLATEST or by constraints that
+// name one.
+func ParseConstraint(constraint string) (Constraint, error) {
+	c := Constraint{raw: constraint}
+	s := strings.TrimSpace(constraint)
+	switch s {
+	case CHANNELLATEST:
+		c.pre = true
+		fallthrough
+	case CHANNELSTABLE, CHANNELOLDSTABLE:
+		c.channel = s
+		return c, nil
+	case "":
+		return Constraint{}, errors.New("empty version constraint")
+	}
+	if parts := xRe.FindStringSubmatch(s); parts != nil {
+		s = "~" + parts[1]
+	}
+	if parts := tildeRe.FindStringSubmatch(s); parts != nil {
+		v, err := VersionFromString(parts[1])
+		if err != nil {
+			return Constraint{}, errors.Wrapf(err, "parsing constraint %q", constraint)
+		}
+		c.bounds = minorBounds(v)
+		c.pre = v.Pre != ""
+		return c, nil
+	}
+	for _, field := range strings.Fields(s) {
+		parts := boundRe.FindStringSubmatch(field)
+		v, err := VersionFromString(parts[2])
+		if err != nil {
+			return Constraint{}, errors.Wrapf(err, "parsing constraint %q", constraint)
+		}
+		if v.Language {
+			c.bounds = append(c.bounds, languageBounds(parts[1], v)...)
+			continue
+		}
+		c.bounds = append(c.bounds, bound{op: parts[1], version: v})
+		c.pre = c.pre || v.Pre != ""
+	}
+	return c, nil
+}
+
+// String returns the constraint as it was parsed.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Matches returns true if v satisfies the constraint, channels are only
+// meaningful against a list of releases, see Resolve.
+func (c Constraint) Matches(v Version) bool {
+	if v.Language || (v.Pre != "" && !c.pre) {
+		return false
+	}
+	for _, b := range c.bounds {
+		if !b.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the newest of releases that satisfies the constraint,
+// false is returned if there is none.
+func (c Constraint) Resolve(releases map[Version]Release) (Version, Release, bool) {
+	switch c.channel {
+	case CHANNELSTABLE:
+		v, r := Newest(releases)
+		return v, r, v != Version{}
+	case CHANNELOLDSTABLE:
+		stable, _ := Newest(releases)
+		for v, r := range NewestPatches(releases) {
+			if v.Major == stable.Major && v.Minor == stable.Minor-1 {
+				return v, r, true
+			}
+		}
+		return Version{}, Release{}, false
+	}
+	found := false
+	var newest Version
+	for v := range releases {
+		if !c.Matches(v) {
+			continue
+		}
+		if !found || v.IsNewerThan(newest) {
+			newest = v
+			found = true
+		}
+	}
+	return newest, releases[newest], found
+}
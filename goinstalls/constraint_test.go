@@ -0,0 +1,114 @@
+package goinstalls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustVersion(t *testing.T, s string) Version {
+	v, err := VersionFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseConstraint(t *testing.T) {
+	for _, test := range []struct {
+		constraint string
+		channel    string
+		bounds     [][2]string
+		pre        bool
+	}{
+		{constraint: "latest", channel: CHANNELLATEST, pre: true},
+		{constraint: "stable", channel: CHANNELSTABLE},
+		{constraint: " oldstable ", channel: CHANNELOLDSTABLE},
+		{constraint: "1.21.3", bounds: [][2]string{{"", "1.21.3"}}},
+		{constraint: "1.21rc2", bounds: [][2]string{{"", "1.21rc2"}}, pre: true},
+		{constraint: "1.21", bounds: [][2]string{{">=", "1.21"}, {"<", "1.22"}}},
+		{constraint: "=1.21", bounds: [][2]string{{">=", "1.21"}, {"<", "1.22"}}},
+		{constraint: "~1.21", bounds: [][2]string{{">=", "1.21"}, {"<", "1.22"}}},
+		{constraint: "1.21.x", bounds: [][2]string{{">=", "1.21"}, {"<", "1.22"}}},
+		{constraint: "~1.21.3", bounds: [][2]string{{">=", "1.21.3"}, {"<", "1.22"}}},
+		{constraint: ">=1.20 <1.22", bounds: [][2]string{{">=", "1.20"}, {"<", "1.22"}}},
+		{constraint: "<=1.21", bounds: [][2]string{{"<", "1.22"}}},
+		{constraint: ">1.21", bounds: [][2]string{{">=", "1.22"}}},
+		{constraint: ">1.21.3 <=1.22.1", bounds: [][2]string{{">", "1.21.3"}, {"<=", "1.22.1"}}},
+	} {
+		t.Run(test.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(test.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var bounds []bound
+			for _, b := range test.bounds {
+				bounds = append(bounds, bound{op: b[0], version: mustVersion(t, b[1])})
+			}
+			if c.channel != test.channel || !reflect.DeepEqual(c.bounds, bounds) || c.pre != test.pre {
+				t.Errorf("parsed as channel %q, bounds %v and pre %v", c.channel, c.bounds, c.pre)
+			}
+			if c.String() != test.constraint {
+				t.Errorf("printed as %q", c.String())
+			}
+		})
+	}
+}
+
+func TestParseConstraintFails(t *testing.T) {
+	for _, constraint := range []string{"", " ", "newest", "~", ">=", ">=1.20 <", "1.21.y", ">=1.20 beta"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("%q was parsed", constraint)
+		}
+	}
+}
+
+func TestConstraintResolve(t *testing.T) {
+	releases := map[Version]Release{}
+	for _, name := range []string{"go1.20.5", "go1.21.0", "go1.21.3", "go1.22.1", "go1.23rc1"} {
+		v, err := VersionFromName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases[v] = Release{Version: name, Stable: v.Pre == ""}
+	}
+	for _, test := range []struct {
+		constraint string
+		want       string
+	}{
+		{constraint: "latest", want: "1.23rc1"},
+		{constraint: "stable", want: "1.22.1"},
+		{constraint: "oldstable", want: "1.21.3"},
+		{constraint: "1.21.0", want: "1.21.0"},
+		{constraint: "1.21", want: "1.21.3"},
+		{constraint: "~1.21", want: "1.21.3"},
+		{constraint: "1.21.x", want: "1.21.3"},
+		{constraint: "~1.21.1", want: "1.21.3"},
+		{constraint: "~1.20.5", want: "1.20.5"},
+		{constraint: ">=1.20 <1.22", want: "1.21.3"},
+		{constraint: "<=1.21", want: "1.21.3"},
+		{constraint: "<1.21", want: "1.20.5"},
+		{constraint: ">1.21", want: "1.22.1"},
+		{constraint: ">=1.21 <=1.21", want: "1.21.3"},
+		{constraint: ">1.21.0 <1.21.3", want: ""},
+		{constraint: ">1.21 <1.22", want: ""},
+		{constraint: "1.23", want: ""},
+		{constraint: "1.23rc1", want: "1.23rc1"},
+		{constraint: "~1.19", want: ""},
+	} {
+		t.Run(test.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(test.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, r, ok := c.Resolve(releases)
+			switch {
+			case test.want == "" && ok:
+				t.Errorf("resolved to %s", v)
+			case test.want != "" && !ok:
+				t.Errorf("resolved to nothing, want %s", test.want)
+			case test.want != "" && (v.String() != test.want || r.Version != "go"+test.want):
+				t.Errorf("resolved to %s (%s), want %s", v, r.Version, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package goinstalls
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/perrito666/goworkon/progress"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CUSTOMPREFIX is the prefix of the names given to custom installs.
+	CUSTOMPREFIX = "devel-"
+	// shortRevision is the length of the revisions used in install names.
+	shortRevision = 12
+)
+
+// CustomSource describes a go tree that is not a go release.
+type CustomSource struct {
+	// Kind is one of SOURCEDIR, SOURCEGIT or SOURCEARCHIVE.
+	Kind string
+	// Location is the go tree folder, the git repository or the tar.gz
+	// archive, which must hold the tree in its go folder.
+	Location string
+	// Ref is the git commit, branch or tag to build, HEAD if empty.
+	Ref string
+}
+
+// ValidInstallName returns an error if name cannot be used for a custom
+// install.
+func ValidInstallName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`+VARIANTSEPARATOR) {
+		return errors.Errorf("%q is not a valid install name", name)
+	}
+	if _, err := VersionFromName(namePrefix + name); err == nil {
+		return errors.Errorf("%q is reserved for the go release of that version", name)
+	}
+	switch name {
+	case CHANNELLATEST, CHANNELSTABLE, CHANNELOLDSTABLE:
+		return errors.Errorf("%q is reserved for the release channel", name)
+	}
+	return nil
+}
+
+// git runs git with the given arguments in dir and returns its trimmed
+// output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Errorf("running git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// isDir returns true if path is an existing folder.
+func isDir(path string) bool {
+	i, err := os.Stat(path)
+	return err == nil && i.IsDir()
+}
+
+// hasGoBinary returns true if the install in installPath has an executable
+// go binary.
+func hasGoBinary(installPath string) bool {
+	i, err := os.Stat(GoBinary(installPath))
+	return err == nil && i.Mode().IsRegular() && i.Mode().Perm()&0111 != 0
+}
+
+// resolvedSource is a CustomSource ready to be assembled into an install.
+type resolvedSource struct {
+	CustomSource
+	// repository is the local git repository to read from.
+	repository string
+	// revision is the full commit or archive checksum.
+	revision string
+	// dirty is true for folders with uncommitted changes.
+	dirty bool
+	// cleanup removes any temporary copy made to resolve the source.
+	cleanup func()
+}
+
+// resolve finds the revision of src, remote repositories are cloned into a
+// temporary folder in tmpDir.
+func resolve(src CustomSource, tmpDir string) (resolvedSource, error) {
+	r := resolvedSource{CustomSource: src, cleanup: func() {}}
+	switch src.Kind {
+	case SOURCEDIR:
+		if !isDir(filepath.Join(src.Location, "src")) {
+			return r, errors.Errorf("%q is not a go tree, it has no src folder", src.Location)
+		}
+		// trees that are not git checkouts have no revision.
+		if sha, err := git(src.Location, "rev-parse", "HEAD"); err == nil {
+			r.revision = sha
+			status, err := git(src.Location, "status", "--porcelain")
+			r.dirty = err != nil || status != ""
+		}
+	case SOURCEGIT:
+		r.repository = src.Location
+		if !isDir(src.Location) {
+			clone, err := ioutil.TempDir(tmpDir, ".clone-")
+			if err != nil {
+				return r, errors.Wrap(err, "creating a folder to clone into")
+			}
+			r.cleanup = func() { os.RemoveAll(clone) }
+			logger.Infof("cloning %q", src.Location)
+			if _, err := git(tmpDir, "clone", "--bare", "--quiet", src.Location, clone); err != nil {
+				r.cleanup()
+				return r, errors.WithStack(err)
+			}
+			r.repository = clone
+		}
+		ref := src.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		sha, err := git(r.repository, "rev-parse", "--verify", ref+"^{commit}")
+		if err != nil {
+			r.cleanup()
+			return r, errors.Wrapf(err, "finding commit %q of %q", ref, src.Location)
+		}
+		r.revision = sha
+	case SOURCEARCHIVE:
+		sum, err := hashFile(src.Location)
+		if err != nil {
+			return r, errors.WithStack(err)
+		}
+		r.revision = sum
+	default:
+		return r, errors.Errorf("unknown custom source kind %q", src.Kind)
+	}
+	return r, nil
+}
+
+// defaultName returns the install name for r, CUSTOMPREFIX followed by its
+// short revision.
+func (r resolvedSource) defaultName() (string, error) {
+	if r.revision == "" {
+		return "", errors.Errorf("%q is not a git checkout, an install name is needed", r.Location)
+	}
+	name := CUSTOMPREFIX + r.revision[:shortRevision]
+	if r.dirty {
+		name += "-dirty"
+	}
+	return name, nil
+}
+
+// copyTree copies the go tree in src into dst, the .git folder is left
+// out.
+func copyTree(src, dst string, task *progress.Task) error {
+	return filepath.Walk(src, func(path string, i os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if i.IsDir() && i.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case i.IsDir():
+			return errors.Wrapf(os.MkdirAll(target, i.Mode().Perm()|0700), "creating %q", target)
+		case i.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "reading link %q", path)
+			}
+			return errors.Wrapf(os.Symlink(link, target), "creating link %q", target)
+		case i.Mode().IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				return errors.Wrapf(err, "opening %q", path)
+			}
+			defer in.Close()
+			return errors.WithStack(writeFile(task.Reader(in), target, i.Mode().Perm()))
+		}
+		return nil
+	})
+}
+
+// gitExport writes the tree of revision in repository into dst/go.
+func gitExport(repository, revision, dst string, task *progress.Task) error {
+	cmd := exec.Command("git", "archive", "--format=tar", "--prefix=go/", revision)
+	cmd.Dir = repository
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "running git archive")
+	}
+	untarErr := untar(tar.NewReader(task.Reader(out)), dst)
+	// drain what untar did not read so git can finish.
+	io.Copy(ioutil.Discard, out)
+	if err := cmd.Wait(); err != nil {
+		return errors.Errorf("running git archive: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return errors.WithStack(untarErr)
+}
+
+// assemble writes the go tree of r into targetPath/go.
+func (r resolvedSource) assemble(subject, targetPath string, opts InstallOptions) error {
+	task := progress.Start(opts.Progress, subject, progress.STAGEEXTRACT, 0)
+	var err error
+	switch r.Kind {
+	case SOURCEDIR:
+		err = copyTree(r.Location, filepath.Join(targetPath, "go"), task)
+	case SOURCEGIT:
+		err = gitExport(r.repository, r.revision, targetPath, task)
+	case SOURCEARCHIVE:
+		err = extract(r.Location, targetPath, task)
+	}
+	task.Done(err)
+	if err != nil {
+		return errors.Wrapf(err, "copying the go tree from %q", r.Location)
+	}
+	if !isDir(filepath.Join(targetPath, "go", "src")) {
+		return errors.Errorf("%q does not hold a go tree", r.Location)
+	}
+	return nil
+}
+
+var goversionRe = regexp.MustCompile(`(?m)^const Version = (\d+)$`)
+
+// treeVersion returns the go version of the go tree in goroot, the VERSION
+// file of release trees is used and, for development trees, the language
+// version they are working on.
+func treeVersion(goroot string) (Version, error) {
+	if contents, err := ioutil.ReadFile(filepath.Join(goroot, "VERSION")); err == nil {
+		firstLine, _ := bufio.NewReader(bytes.NewReader(contents)).ReadString('\n')
+		if v, err := VersionFromName(strings.TrimSpace(firstLine)); err == nil {
+			return v, nil
+		}
+	}
+	goversionFile := filepath.Join(goroot, "src", "internal", "goversion", "goversion.go")
+	contents, err := ioutil.ReadFile(goversionFile)
+	if err != nil {
+		return Version{}, errors.Wrapf(err, "finding the go version of %q", goroot)
+	}
+	parts := goversionRe.FindSubmatch(contents)
+	if parts == nil {
+		return Version{}, errors.Errorf("finding the go version of %q: no version in %q", goroot, goversionFile)
+	}
+	minor, _ := strconv.Atoi(string(parts[1]))
+	return Version{Major: 1, Minor: minor, Language: true}, nil
+}
+
+// ensureVersionFile writes the VERSION file that the go build requires for
+// trees that are not git checkouts.
+func ensureVersionFile(goroot, name string, v Version) error {
+	fileName := filepath.Join(goroot, "VERSION")
+	if _, err := os.Stat(fileName); err == nil {
+		return nil
+	}
+	contents := "devel go" + v.CommonVersionString() + "-" + strings.TrimPrefix(name, CUSTOMPREFIX) + "\n"
+	return errors.Wrapf(ioutil.WriteFile(fileName, []byte(contents), 0644), "writing %q", fileName)
+}
+
+// InstallCustom builds the go tree described by src into an install in
+// installsFolder and returns its name, which is name or, if empty, one
+// derived from the revision of the tree. bootstrap is called to obtain the
+// GOROOT to compile the tree with, archives of prebuilt trees are not
+// compiled.
+func InstallCustom(src CustomSource, name, installsFolder string,
+	bootstrap func(Version) (string, error), opts InstallOptions) (string, error) {
+	if err := os.MkdirAll(installsFolder, 0700); err != nil {
+		return "", errors.Wrapf(err, "creating installs folder %q", installsFolder)
+	}
+	r, err := resolve(src, installsFolder)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer r.cleanup()
+	if name == "" {
+		if name, err = r.defaultName(); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	if err := ValidInstallName(name); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	installPath := filepath.Join(installsFolder, name)
+	unlock, err := lockInstall(name, opts)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer unlock()
+	// folders can change without a new commit, the rest are only rebuilt
+	// if they are not the same revision.
+	if info, err := ReadInstallInfo(installPath); err == nil && CheckInstall(installPath) == "" &&
+		src.Kind != SOURCEDIR && info.Source == src.Kind && info.Revision == r.revision {
+		logger.Infof("%q is already installed from %q", name, src.Location)
+		return name, nil
+	}
+	workPath := hiddenSibling(installPath, partialInstallSuffix)
+	if err := os.RemoveAll(workPath); err != nil {
+		return "", errors.Wrapf(err, "removing %q", workPath)
+	}
+	if err := r.assemble(name, workPath, opts); err != nil {
+		return "", errors.Wrapf(err, "installing %q, what was done is left in %q", name, workPath)
+	}
+	goroot := filepath.Join(workPath, "go")
+	v, err := treeVersion(goroot)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	info := InstallInfo{
+		Version:  v.String(),
+		Source:   src.Kind,
+		Origin:   src.Location,
+		Revision: r.revision,
+	}
+	m := Manifest{SourceURL: src.Location}
+	if src.Kind == SOURCEARCHIVE {
+		info.Archive = filepath.Base(src.Location)
+		info.SHA256 = r.revision
+		m.SHA256 = r.revision
+	}
+	// prebuilt archives are used as they are.
+	if src.Kind != SOURCEARCHIVE || !hasGoBinary(workPath) {
+		if err := ensureVersionFile(goroot, name, v); err != nil {
+			return "", errors.WithStack(err)
+		}
+		if opts.Goroot, err = bootstrap(v); err != nil {
+			return "", errors.Wrapf(err, "finding a go to build %q", name)
+		}
+		if err := checkCanBuild(v, opts); err != nil {
+			return "", errors.WithStack(err)
+		}
+		if err := buildTree(name, workPath, installPath, opts, &m); err != nil {
+			return "", errors.Wrapf(err, "installing %q, what was done is left in %q", name, workPath)
+		}
+	}
+	return name, errors.WithStack(commitInstall(workPath, installPath, info, m, opts.GoEnv))
+}
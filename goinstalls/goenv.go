@@ -0,0 +1,224 @@
+package goinstalls
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// GOENVFILE is the name of the file, in GOROOT, holding the defaults
+	// of the go command configuration, since go 1.21.
+	GOENVFILE = "go.env"
+	// GOENVORIGINALFILE is the name of the file, in the install folder,
+	// where the go.env the install came with is kept.
+	GOENVORIGINALFILE = "go.env.orig"
+
+	// firstGoEnvMinor is the first minor that reads GOENVFILE.
+	firstGoEnvMinor = 21
+	// goEnvMarker starts the lines goworkon adds to GOENVFILE.
+	goEnvMarker = "# set by goworkon from its goenv settings, changes below this line are overwritten."
+)
+
+// goEnvNameRe matches the names go accepts in GOENVFILE.
+var goEnvNameRe = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ValidGoEnvName returns an error if name cannot be set in GOENVFILE.
+func ValidGoEnvName(name string) error {
+	if !goEnvNameRe.MatchString(name) {
+		return errors.Errorf("%q is not a valid go environment variable name", name)
+	}
+	return nil
+}
+
+// GoEnv holds the defaults goworkon writes into the GOENVFILE of installs.
+type GoEnv struct {
+	// Defaults apply to every install.
+	Defaults map[string]string `json:"defaults,omitempty"`
+	// Versions holds the defaults for some installs, keyed by install
+	// name or language version (ie: 1.21 for all the 1.21 installs), they
+	// override Defaults and the install name ones override the language
+	// version ones.
+	Versions map[string]map[string]string `json:"versions,omitempty"`
+}
+
+// For returns the defaults for the install called installName of go v.
+func (e GoEnv) For(installName string, v Version) map[string]string {
+	vars := map[string]string{}
+	for _, set := range []map[string]string{e.Defaults, e.Versions[v.Lang().String()], e.Versions[installName]} {
+		for name, value := range set {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// Set returns a copy of e with name set to value for the installs of
+// version, all of them if empty, an empty value removes name.
+func (e GoEnv) Set(version, name, value string) GoEnv {
+	set := func(vars map[string]string) map[string]string {
+		copied := map[string]string{}
+		for n, v := range vars {
+			copied[n] = v
+		}
+		if value == "" {
+			delete(copied, name)
+		} else {
+			copied[name] = value
+		}
+		if len(copied) == 0 {
+			return nil
+		}
+		return copied
+	}
+	if version == "" {
+		e.Defaults = set(e.Defaults)
+		return e
+	}
+	versions := map[string]map[string]string{}
+	for v, vars := range e.Versions {
+		versions[v] = vars
+	}
+	if vars := set(versions[version]); vars != nil {
+		versions[version] = vars
+	} else {
+		delete(versions, version)
+	}
+	e.Versions = nil
+	if len(versions) > 0 {
+		e.Versions = versions
+	}
+	return e
+}
+
+// readsGoEnv returns true if go v reads GOENVFILE.
+func readsGoEnv(v Version) bool {
+	return v.Major > 1 || v.Minor >= firstGoEnvMinor
+}
+
+// writeGoEnv writes into the go tree of the install in installPath the
+// original GOENVFILE, without the variables in vars, followed by vars, the
+// original is kept in GOENVORIGINALFILE the first time. True is returned if
+// GOENVFILE changed.
+func writeGoEnv(installPath string, vars map[string]string) (bool, error) {
+	goEnvPath := filepath.Join(installPath, "go", GOENVFILE)
+	originalPath := filepath.Join(installPath, GOENVORIGINALFILE)
+	original, err := ioutil.ReadFile(originalPath)
+	if os.IsNotExist(err) {
+		original, err = ioutil.ReadFile(goEnvPath)
+		if os.IsNotExist(err) {
+			original, err = []byte{}, nil
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "reading %q", goEnvPath)
+		}
+		if err := ioutil.WriteFile(originalPath, original, 0644); err != nil {
+			return false, errors.Wrapf(err, "keeping the original %q", goEnvPath)
+		}
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "reading %q", originalPath)
+	}
+
+	contents := &bytes.Buffer{}
+	for _, line := range bytes.SplitAfter(original, []byte("\n")) {
+		// go keeps the first value of each variable in GOENVFILE, the
+		// original ones are commented out so those in vars are used.
+		if i := bytes.IndexByte(line, '='); i > 0 && vars[string(line[:i])] != "" {
+			contents.WriteString("# ")
+		}
+		contents.Write(line)
+	}
+	if len(vars) > 0 {
+		if contents.Len() > 0 {
+			if !bytes.HasSuffix(original, []byte("\n")) {
+				contents.WriteString("\n")
+			}
+			contents.WriteString("\n")
+		}
+		fmt.Fprintln(contents, goEnvMarker)
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(contents, "%s=%s\n", name, vars[name])
+		}
+	}
+	current, err := ioutil.ReadFile(goEnvPath)
+	switch {
+	case os.IsNotExist(err) && contents.Len() == 0:
+		return false, nil
+	case err == nil && bytes.Equal(current, contents.Bytes()):
+		return false, nil
+	case err == nil && contents.Len() == 0:
+		// the install came with no GOENVFILE.
+		return true, errors.Wrapf(os.Remove(goEnvPath), "removing %q", goEnvPath)
+	}
+	if err := ioutil.WriteFile(goEnvPath, contents.Bytes(), 0644); err != nil {
+		return false, errors.Wrapf(err, "writing %q", goEnvPath)
+	}
+	return true, nil
+}
+
+// installGoEnv writes the defaults in goEnv for the install called name in
+// installPath, described by info, into its GOENVFILE. Adopted installs, which goworkon
+// does not modify, and installs of go versions that do not read GOENVFILE
+// are left untouched. True is returned if GOENVFILE changed.
+func installGoEnv(name, installPath string, info InstallInfo, goEnv GoEnv) (bool, error) {
+	if info.Adopted() {
+		return false, nil
+	}
+	v, err := VersionFromName(namePrefix + info.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing the version of %q", installPath)
+	}
+	if !readsGoEnv(v) {
+		return false, nil
+	}
+	changed, err := writeGoEnv(installPath, goEnv.For(name, v))
+	return changed, errors.WithStack(err)
+}
+
+// ApplyGoEnv writes the defaults in goEnv into the GOENVFILE of every
+// install in installsFolder that reads it, updating their manifests, see
+// installGoEnv.
+func ApplyGoEnv(installsFolder string, goEnv GoEnv, opts InstallOptions) error {
+	installs, err := Installs(installsFolder)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, install := range installs {
+		if install.Broken != "" {
+			logger.Debugf("not writing %s into the broken install %q", GOENVFILE, install.Name)
+			continue
+		}
+		if err := applyGoEnv(install, goEnv, opts); err != nil {
+			return errors.Wrapf(err, "writing %s of %q", GOENVFILE, install.Name)
+		}
+	}
+	return nil
+}
+
+// applyGoEnv writes the defaults in goEnv into the GOENVFILE of install
+// and records the change in its manifest.
+func applyGoEnv(install Install, goEnv GoEnv, opts InstallOptions) error {
+	unlock, err := lockInstall(install.Name, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer unlock()
+	changed, err := installGoEnv(install.Name, install.Path, install.Info, goEnv)
+	if err != nil || !changed {
+		return errors.WithStack(err)
+	}
+	logger.Infof("updated %s of %q", GOENVFILE, install.Name)
+	return errors.WithStack(updateManifest(install.Path, GOENVFILE))
+}
@@ -2,27 +2,25 @@ package goinstalls
 
 import (
 	"archive/tar"
-	"bytes"
 	"compress/gzip"
-	"encoding/xml"
-	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sort"
+	"time"
 
 	"github.com/juju/loggo"
+	"github.com/perrito666/goworkon/lockfile"
+	"github.com/perrito666/goworkon/progress"
 	"github.com/pkg/errors"
 )
 
-// GODLURL is the url from which golang src tars can be dowloaded.
-const GODLURL = "https://storage.googleapis.com/golang/"
-const srcPrefix = ".src.tar.gz"
+// GODLURL is the url from which golang release files can be dowloaded.
+const GODLURL = "https://dl.google.com/go/"
+const binSuffix = ".tar.gz"
 
 var logger = loggo.GetLogger("goworkon.goinstalls")
 
-func filterNewer(vers map[Version]string) map[Version]string {
+func filterNewer(vers map[Version]Release) map[Version]Release {
 	unique := map[string]Version{}
 	for k := range vers {
 		s := k.CommonVersionString()
@@ -35,145 +33,350 @@ func filterNewer(vers map[Version]string) map[Version]string {
 			unique[s] = k
 		}
 	}
-	result := make(map[Version]string, len(unique))
+	result := make(map[Version]Release, len(unique))
 	for _, v := range unique {
 		result[v] = vers[v]
 	}
 	return result
 }
 
-// OnlineAvailableVersions returns a map of all found versions grouped
-// by Minor number and with the latest patch of said Minor as value.
-func OnlineAvailableVersions() (map[Version]string, error) {
-	response, err := http.Get(GODLURL)
-	if err != nil {
-		return nil, errors.WithStack(err)
+// OnlineReleases returns all the releases in the release feed keyed by
+// their Version, releases with unparseable versions are skipped. If module
+// proxies are configured the toolchain modules they serve are added to the
+// files of the releases, only those are returned if the feed cannot be
+// reached.
+func OnlineReleases() (map[Version]Release, error) {
+	releases, feedErr := allReleases()
+	versions := make(map[Version]Release, len(releases))
+	for _, r := range releases {
+		v, err := VersionFromName(r.Version)
+		if err != nil {
+			logger.Debugf("skipping release %q: %v", r.Version, err)
+			continue
+		}
+		versions[v] = r
 	}
-	defer response.Body.Close()
-	var rawXML bytes.Buffer
-	_, err = io.Copy(&rawXML, response.Body)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	if len(goProxies()) == 0 {
+		return versions, errors.WithStack(feedErr)
 	}
-	var downloadables golangDownloadsPageContents
-	if err := xml.Unmarshal(rawXML.Bytes(), &downloadables); err != nil {
-		return nil, errors.WithStack(err)
+	toolchains, err := ToolchainReleases()
+	switch {
+	case err != nil && feedErr != nil:
+		return nil, errors.Wrapf(err, "the release feed cannot be reached either (%v)", feedErr)
+	case err != nil:
+		logger.Warningf("toolchain modules cannot be installed: %v", err)
+		return versions, nil
+	case feedErr != nil:
+		logger.Warningf("the release feed cannot be reached, only toolchain modules can be installed: %v", feedErr)
 	}
-	versions := map[Version]string{}
-	for _, dl := range downloadables.Contents {
-		if strings.HasSuffix(dl.Key, "src.tar.gz") {
-			versionString := dl.Key[2 : len(dl.Key)-len(srcPrefix)]
-			v, err := VersionFromString(versionString)
-			if err != nil {
-				continue
-			}
-			versions[v] = dl.Key
+	for v, toolchain := range toolchains {
+		release, ok := versions[v]
+		if !ok {
+			versions[v] = toolchain
+			continue
 		}
+		release.Files = append(append([]ReleaseFile{}, release.Files...), toolchain.Files...)
+		versions[v] = release
 	}
-	return filterNewer(versions), nil
+	return versions, nil
 }
 
-// NewestAvailableOnline returns the newest version available to download.
-func NewestAvailableOnline() (Version, string, error) {
-	newerOnline, err := OnlineAvailableVersions()
-	if err != nil {
-		return Version{}, "", errors.Wrap(err, "finding available online versions")
+// prebuiltFile returns the file of release to install it for platform, its
+// toolchain module when module proxies are configured and its archive
+// otherwise, either falls back to the other.
+func prebuiltFile(release Release, platform Platform) (ReleaseFile, bool) {
+	kinds := []FileKind{KINDARCHIVE, KINDMODULE}
+	if len(goProxies()) > 0 {
+		kinds = []FileKind{KINDMODULE, KINDARCHIVE}
+	}
+	for _, kind := range kinds {
+		if file, ok := release.File(kind, platform.OS, platform.Arch); ok {
+			return file, true
+		}
+	}
+	return ReleaseFile{}, false
+}
+
+// NewestPatches returns the stable releases in releases grouped by Minor
+// number and with the latest patch of said Minor as key.
+func NewestPatches(releases map[Version]Release) map[Version]Release {
+	stable := map[Version]Release{}
+	for v, r := range releases {
+		if r.Stable {
+			stable[v] = r
+		}
 	}
+	return filterNewer(stable)
+}
+
+// Newest returns the newest of the stable releases in releases.
+func Newest(releases map[Version]Release) (Version, Release) {
 	newest := Version{}
-	newestSrc := ""
-	for v, src := range newerOnline {
+	newestRelease := Release{}
+	for v, r := range NewestPatches(releases) {
 		if v.IsNewerThan(newest) {
 			newest = v
-			newestSrc = src
+			newestRelease = r
 		}
 	}
-	return newest, newestSrc, nil
+	return newest, newestRelease
 }
 
-// InstalledAvailableVersions returns a slice of the Versions that
-// have a current install locally.
-func InstalledAvailableVersions() []Version {
-	return []Version{}
+// SortedVersions returns the versions of releases sorted from newest to
+// oldest.
+func SortedVersions(releases map[Version]Release) []Version {
+	versions := make(Versions, 0, len(releases))
+	for v := range releases {
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(versions))
+	return versions
 }
 
-func untar(tarFile *tar.Reader, targetPath string) error {
-	logger.Debugf("extracting into %q", targetPath)
-	for {
-		h, err := tarFile.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return errors.Wrap(err, "uncompressing headers")
-		}
-		p := filepath.Join(targetPath, h.Name)
-		logger.Debugf(p)
-		i := h.FileInfo()
-		if i.IsDir() {
-			err := os.MkdirAll(p, i.Mode())
-			if err != nil {
-				return errors.Wrapf(err, "creating folder %q", p)
-			}
-			continue
-		}
-		err = func() error {
-			fp, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, i.Mode())
-			if err != nil {
-				return errors.Wrapf(err, "extracting file %q", h.Name)
-			}
-			defer fp.Close()
-			_, err = io.Copy(fp, tarFile)
-			if err != nil {
-				return errors.Wrapf(err, "writing file %q", h.Name)
-			}
-			return nil
-		}()
-		if err != nil {
-			return errors.Wrap(err, "running extract")
-		}
+// OnlineAvailableVersions returns a map of all found stable versions grouped
+// by Minor number and with the latest patch of said Minor as key.
+func OnlineAvailableVersions() (map[Version]Release, error) {
+	releases, err := OnlineReleases()
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	return nil
+	return NewestPatches(releases), nil
+}
+
+// NewestAvailableOnline returns the newest version available to download.
+func NewestAvailableOnline() (Version, Release, error) {
+	releases, err := OnlineReleases()
+	if err != nil {
+		return Version{}, Release{}, errors.Wrap(err, "finding available online versions")
+	}
+	newest, newestRelease := Newest(releases)
+	return newest, newestRelease, nil
 }
 
-// InstallVersion downloads, extracts and installs the given go version.
-func InstallVersion(v Version, src, targetPath, goroot string) error {
+// InstallOptions holds the settings that determine how a go version
+// is installed.
+type InstallOptions struct {
+	// FromSource indicates that go must be compiled from the source
+	// archive instead of unpacking the prebuilt one for this host.
+	FromSource bool
+	// Goroot is the path to a working goroot used to bootstrap
+	// source builds, see Bootstrap.
+	Goroot string
+	// CacheDir is the folder where downloaded archives are kept.
+	CacheDir string
+	// Offline restricts installs to the archives in CacheDir.
+	Offline bool
+	// BuildMode is the way source builds are run, one of BUILDMAKE,
+	// BUILDALL or BUILDALLALLOWFAIL, defaults to BUILDMAKE.
+	BuildMode string
+	// LockDir is the folder where the lock files that prevent concurrent
+	// installs of the same version are created, no locking is done if empty.
+	LockDir string
+	// Progress receives the progress of downloads, extractions and builds,
+	// nothing is reported if nil.
+	Progress progress.Reporter
+	// Platform is the platform go is installed for, the host if empty,
+	// only host installs can be built from source.
+	Platform Platform
+	// GoEnv holds the defaults written into the GOENVFILE of installs.
+	GoEnv GoEnv
+	// Variant is the build variant to install, the vanilla toolchain if
+	// its Name is empty, variants are only built from source for the host.
+	Variant Variant
+}
 
-	response, err := http.Get(GODLURL + src)
+// extract uncompresses the tar.gz in archivePath into targetPath, the
+// progress is reported in compressed bytes read.
+func extract(archivePath, targetPath string, task *progress.Task) error {
+	fp, err := os.Open(archivePath)
 	if err != nil {
-		return errors.WithStack(err)
+		return errors.Wrapf(err, "opening %q", archivePath)
+	}
+	defer fp.Close()
+	if i, err := fp.Stat(); err == nil {
+		task.SetTotal(i.Size())
 	}
-	defer response.Body.Close()
-	gzFile, err := gzip.NewReader(response.Body)
+	gzFile, err := gzip.NewReader(task.Reader(fp))
 	if err != nil {
 		return errors.Wrap(err, "gunzipping file")
 	}
 	tarFile := tar.NewReader(gzFile)
 
-	targetPath = filepath.Join(targetPath, v.String())
+	return errors.WithStack(untar(tarFile, targetPath))
+}
 
-	if err := untar(tarFile, targetPath); err != nil {
-		return errors.WithStack(err)
+// downloadAndExtract fetches the given file of go v, verifies it against its
+// published SHA-256 and extracts it into targetPath, the verified
+// checksum is recorded in targetPath and, along with where the file came
+// from, in m.
+func downloadAndExtract(v Version, file ReleaseFile, targetPath string, opts InstallOptions, m *Manifest) error {
+	archive := file.Filename
+	expected, err := parseChecksum(file.SHA256)
+	if err != nil {
+		return errors.Wrapf(err, "refusing to install %q without a checksum", archive)
 	}
-
-	os.Setenv("GOROOT_BOOTSTRAP", goroot)
-	defer os.Unsetenv("GOROOT_BOOTSTRAP")
-	cwd, err := os.Getwd()
+	archivePath, sourceURL, err := download(v, file, expected, opts)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	defer os.Chdir(cwd)
-	srcPath := filepath.Join(targetPath, "go", "src")
-	err = os.Chdir(srcPath)
+	m.SourceURL = sourceURL
+	m.SHA256 = expected
+	task := progress.Start(opts.Progress, InstallName(v, opts.Platform), progress.STAGEEXTRACT, 0)
+	err = extract(archivePath, targetPath, task)
+	task.Done(err)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	cwd, err = os.Getwd()
+	return errors.WithStack(recordChecksum(targetPath, archive, expected))
+}
+
+// installLockTimeout is how long an install waits for another process
+// installing the same version, builds from source can take a while.
+const installLockTimeout = time.Hour
+
+// lockInstall takes the lock for installing name, the returned function
+// releases it.
+func lockInstall(name string, opts InstallOptions) (func(), error) {
+	if opts.LockDir == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(opts.LockDir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "creating locks folder %q", opts.LockDir)
+	}
+	lock, err := lockfile.Acquire(filepath.Join(opts.LockDir, "install-"+name+".lock"), installLockTimeout)
 	if err != nil {
+		return nil, errors.Wrapf(err, "locking the install of %q", name)
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}, nil
+}
+
+const (
+	// partialInstallSuffix is appended to the hidden folder where an
+	// install is assembled.
+	partialInstallSuffix = ".partial"
+	// oldInstallSuffix is appended to the hidden folder where a previous
+	// install is moved while being replaced.
+	oldInstallSuffix = ".old"
+)
+
+// hiddenSibling returns the path of a hidden folder next to installPath.
+func hiddenSibling(installPath, suffix string) string {
+	return filepath.Join(filepath.Dir(installPath), "."+filepath.Base(installPath)+suffix)
+}
+
+// commitInstall writes the defaults in goEnv into the GOENVFILE of the
+// install assembled in workPath, records its manifest m, marks it as
+// complete and moves it into installPath, replacing any previous install
+// there. Adopted installs get no manifest, their tree is not managed by
+// goworkon.
+func commitInstall(workPath, installPath string, info InstallInfo, m Manifest, goEnv GoEnv) error {
+	info.Installed = time.Now()
+	if !info.Adopted() {
+		if _, err := installGoEnv(filepath.Base(installPath), workPath, info, goEnv); err != nil {
+			return errors.WithStack(err)
+		}
+		m.Version = info.Version
+		m.Created = info.Installed
+		if err := writeManifest(workPath, m); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := writeInstallInfo(workPath, info); err != nil {
 		return errors.WithStack(err)
 	}
+	oldPath := ""
+	if _, err := os.Lstat(installPath); err == nil {
+		oldPath = hiddenSibling(installPath, oldInstallSuffix)
+		if err := os.RemoveAll(oldPath); err != nil {
+			return errors.Wrapf(err, "removing %q", oldPath)
+		}
+		if err := os.Rename(installPath, oldPath); err != nil {
+			return errors.Wrapf(err, "moving away the previous install %q", installPath)
+		}
+	}
+	if err := os.Rename(workPath, installPath); err != nil {
+		if oldPath != "" {
+			os.Rename(oldPath, installPath)
+		}
+		return errors.Wrapf(err, "moving the install into %q", installPath)
+	}
+	if oldPath != "" {
+		return errors.Wrapf(os.RemoveAll(oldPath), "removing the previous install %q", oldPath)
+	}
+	return nil
+}
 
-	cmd := exec.Command(filepath.Join(srcPath, "all.bash"))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return errors.WithStack(cmd.Run())
+// InstallVersion downloads, extracts and installs the given go version,
+// by default the prebuilt archive for opts.Platform is used, or its
+// toolchain module if module proxies are configured, see prebuiltFile,
+// unless opts requests a build from source, which build variants require.
+// The install, named by InstallName, is assembled in a hidden folder next
+// to its final place and moved there, marked as complete, only if it
+// succeeds.
+func InstallVersion(v Version, release Release, targetPath string, opts InstallOptions) error {
+	platform := opts.Platform.Resolved()
+	name := WithVariant(InstallName(v, platform), opts.Variant.Name)
+	installPath := filepath.Join(targetPath, name)
+	if opts.FromSource && !platform.IsHost() {
+		return errors.Errorf("go %q for %s cannot be built from source, only host installs can", v.String(), platform)
+	}
+	if opts.Variant.Name != "" {
+		if err := ensureVariantBuild(platform, opts); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	unlock, err := lockInstall(name, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer unlock()
+	// another process might have installed it while we waited.
+	if opts.LockDir != "" && CheckInstall(installPath) == "" {
+		logger.Infof("go %q was installed by another process", name)
+		return nil
+	}
+	workPath := hiddenSibling(installPath, partialInstallSuffix)
+	// leftovers of a previous failed attempt.
+	if err := os.RemoveAll(workPath); err != nil {
+		return errors.Wrapf(err, "removing %q", workPath)
+	}
+	var file ReleaseFile
+	var ok bool
+	var m Manifest
+	info := InstallInfo{
+		Version: v.String(),
+		OS:      platform.OS,
+		Arch:    platform.Arch,
+	}
+	if opts.FromSource {
+		file, ok = release.File(KINDSOURCE, "", "")
+		if !ok {
+			return errors.Errorf("there is no source archive for go %q", v.String())
+		}
+		info.Source = SOURCEBUILD
+		if opts.Variant.Name != "" {
+			variant := opts.Variant
+			info.Variant = &variant
+		}
+		err = installFromSource(v, file, workPath, installPath, opts, &m)
+	} else if file, ok = prebuiltFile(release, platform); ok && file.Kind == KINDMODULE {
+		info.Source = SOURCETOOLCHAIN
+		err = installToolchain(v, file, workPath, opts, &m)
+	} else if ok {
+		info.Source = SOURCEPREBUILT
+		err = downloadAndExtract(v, file, workPath, opts, &m)
+	} else {
+		return errors.Errorf("there is no prebuilt go %q for %s (try building it from source)",
+			v.String(), platform)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "installing go %q, what was done is left in %q", v.String(), workPath)
+	}
+	info.Archive = file.Filename
+	info.SHA256 = file.SHA256
+	return errors.WithStack(commitInstall(workPath, installPath, info, m, opts.GoEnv))
 }
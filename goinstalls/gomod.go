@@ -0,0 +1,47 @@
+package goinstalls
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GOMODFILE is the name of the file declaring a go module.
+const GOMODFILE = "go.mod"
+
+// toolchainDefault is the toolchain line value that means the go line
+// applies.
+const toolchainDefault = "default"
+
+// GoModToolchain returns the go release required by the toolchain line,
+// ie: toolchain go1.21.3, of the go.mod in path, false is returned if the
+// file has none.
+func GoModToolchain(path string) (Version, bool, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return Version{}, false, errors.Wrapf(err, "opening %q", path)
+	}
+	defer fp.Close()
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "toolchain" {
+			continue
+		}
+		if fields[1] == toolchainDefault {
+			return Version{}, false, nil
+		}
+		v, err := VersionFromName(fields[1])
+		if err != nil {
+			return Version{}, false, errors.Wrapf(err, "parsing the toolchain line of %q", path)
+		}
+		return v, true, nil
+	}
+	return Version{}, false, errors.Wrapf(scanner.Err(), "reading %q", path)
+}
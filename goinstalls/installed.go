@@ -0,0 +1,266 @@
+package goinstalls
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// INSTALLINFOFILE is the name of the file that marks an install as
+	// complete, it holds its InstallInfo.
+	INSTALLINFOFILE = "INSTALLED"
+
+	// SOURCEPREBUILT is the InstallInfo.Source of installs made from the
+	// prebuilt archives.
+	SOURCEPREBUILT = "prebuilt"
+	// SOURCEBUILD is the InstallInfo.Source of installs compiled from the
+	// source archives.
+	SOURCEBUILD = "source"
+	// SOURCETOOLCHAIN is the InstallInfo.Source of installs made from the
+	// toolchain modules of a module proxy.
+	SOURCETOOLCHAIN = "toolchain"
+	// SOURCEDIR is the InstallInfo.Source of installs built from a local
+	// go tree.
+	SOURCEDIR = "dir"
+	// SOURCEGIT is the InstallInfo.Source of installs built from a commit
+	// of a go git repository.
+	SOURCEGIT = "git"
+	// SOURCEARCHIVE is the InstallInfo.Source of installs made from a
+	// local archive.
+	SOURCEARCHIVE = "archive"
+)
+
+// InstallInfo holds the metadata of a complete install.
+type InstallInfo struct {
+	// Version is the go version installed, custom installs hold the go
+	// version their tree is based on.
+	Version string `json:"version"`
+	// Source is how the install was made, ie: SOURCEPREBUILT.
+	Source string `json:"source"`
+	// Archive is the name of the archive the install came from.
+	Archive string `json:"archive"`
+	// SHA256 is the verified checksum of Archive.
+	SHA256 string `json:"sha256"`
+	// Installed is the moment the install was completed.
+	Installed time.Time `json:"installed"`
+	// Origin is the directory, repository or archive custom installs
+	// were made from or the goroot of adopted ones.
+	Origin string `json:"origin,omitempty"`
+	// Revision is the commit custom installs were built from, if known.
+	Revision string `json:"revision,omitempty"`
+	// OS is the GOOS the install runs on, installs made before platforms
+	// where recorded are for the host.
+	OS string `json:"os,omitempty"`
+	// Arch is the GOARCH the install runs on.
+	Arch string `json:"arch,omitempty"`
+	// Variant is the build variant installed, nil for vanilla toolchains.
+	Variant *Variant `json:"variant,omitempty"`
+}
+
+// Platform returns the platform the install runs on.
+func (i InstallInfo) Platform() Platform {
+	return Platform{OS: i.OS, Arch: i.Arch}.Resolved()
+}
+
+// Custom returns true for installs that do not come from a go release.
+func (i InstallInfo) Custom() bool {
+	switch i.Source {
+	case SOURCEDIR, SOURCEGIT, SOURCEARCHIVE:
+		return true
+	}
+	return false
+}
+
+// writeInstallInfo writes info in the install folder, marking it complete.
+func writeInstallInfo(installPath string, info InstallInfo) error {
+	marshaled, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "marshaling install info")
+	}
+	fileName := filepath.Join(installPath, INSTALLINFOFILE)
+	if err := ioutil.WriteFile(fileName, marshaled, 0600); err != nil {
+		return errors.Wrapf(err, "writing %q", fileName)
+	}
+	return nil
+}
+
+// ReadInstallInfo returns the InstallInfo of the install in installPath,
+// installs that did not complete have none.
+func ReadInstallInfo(installPath string) (InstallInfo, error) {
+	fileName := filepath.Join(installPath, INSTALLINFOFILE)
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return InstallInfo{}, errors.Wrapf(err, "reading %q", fileName)
+	}
+	var info InstallInfo
+	if err := json.Unmarshal(contents, &info); err != nil {
+		return InstallInfo{}, errors.Wrapf(err, "unmarshaling %q", fileName)
+	}
+	return info, nil
+}
+
+// Install represents a go install found in the installs folder.
+type Install struct {
+	// Name is the name of the install folder.
+	Name string
+	// Version is the go version of the install.
+	Version Version
+	// Platform is the platform the install runs on.
+	Platform Platform
+	// Path is the path of the install folder.
+	Path string
+	// Size is the disk usage of the install in bytes.
+	Size int64
+	// Installed is the moment the install was created.
+	Installed time.Time
+	// LastUsed is the last moment an environment was switched to this
+	// install, it is the install time if it was never used.
+	LastUsed time.Time
+	// Broken describes what is wrong with the install, it is empty
+	// for usable installs.
+	Broken string
+	// Info holds the metadata of the install, it is empty for broken
+	// installs.
+	Info InstallInfo
+	// Variant is the name of the build variant of the install, empty for
+	// vanilla toolchains.
+	Variant string
+}
+
+// GoBinary returns the path to the go binary of an install in installPath.
+func GoBinary(installPath string) string {
+	return filepath.Join(installPath, "go", "bin", "go")
+}
+
+// CheckInstall returns a description of what is wrong with the install
+// in installPath or an empty string if it is usable.
+func CheckInstall(installPath string) string {
+	if _, err := ReadInstallInfo(installPath); err != nil {
+		return "not marked as complete, the install did not finish"
+	}
+	i, err := os.Stat(GoBinary(installPath))
+	if err != nil {
+		return "missing go/bin/go"
+	}
+	if !i.Mode().IsRegular() || i.Mode().Perm()&0111 == 0 {
+		return "go/bin/go is not an executable"
+	}
+	return ""
+}
+
+// installedAt returns the moment the install was completed, the folder
+// creation is used for installs without info.
+func installedAt(info InstallInfo, folder os.FileInfo) time.Time {
+	if !info.Installed.IsZero() {
+		return info.Installed
+	}
+	return folder.ModTime()
+}
+
+// LASTUSEDFILE is the name of the file, in the install folder, whose
+// modification time is the last time the install was used.
+const LASTUSEDFILE = "LASTUSED"
+
+// MarkUsed records that the install in installPath is being used now.
+func MarkUsed(installPath string) error {
+	fileName := filepath.Join(installPath, LASTUSEDFILE)
+	now := time.Now()
+	if err := ioutil.WriteFile(fileName, []byte(now.Format(time.RFC3339)+"\n"), 0600); err != nil {
+		return errors.Wrapf(err, "recording use of %q", installPath)
+	}
+	return nil
+}
+
+// lastUsedAt returns the last time the install in installPath was used or
+// installed if it was never used.
+func lastUsedAt(installPath string, installed time.Time) time.Time {
+	if i, err := os.Stat(filepath.Join(installPath, LASTUSEDFILE)); err == nil {
+		return i.ModTime()
+	}
+	return installed
+}
+
+// diskUsage returns the sum of the sizes of all files under path.
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, i os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if i.Mode().IsRegular() {
+			size += i.Size()
+		}
+		return nil
+	})
+	return size, errors.Wrapf(err, "calculating disk usage of %q", path)
+}
+
+// Installs returns all the installs found in installsFolder, broken
+// ones included, sorted from newest to oldest version.
+func Installs(installsFolder string) ([]Install, error) {
+	entries, err := ioutil.ReadDir(installsFolder)
+	if os.IsNotExist(err) {
+		return []Install{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading installs folder %q", installsFolder)
+	}
+	installs := []Install{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		install := Install{
+			Name: entry.Name(),
+			Path: filepath.Join(installsFolder, entry.Name()),
+		}
+		install.Info, _ = ReadInstallInfo(install.Path)
+		install.Installed = installedAt(install.Info, entry)
+		install.LastUsed = lastUsedAt(install.Path, install.Installed)
+		install.Size, err = diskUsage(install.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		install.Version, install.Platform, err = ParseInstallName(install.Name)
+		_, install.Variant = SplitVariant(install.Name)
+		switch {
+		case install.Info.Custom():
+			install.Version, _ = VersionFromString(install.Info.Version)
+			install.Platform = install.Info.Platform()
+			install.Broken = CheckInstall(install.Path)
+		case err != nil:
+			install.Broken = "not named after a go version"
+		default:
+			install.Broken = CheckInstall(install.Path)
+		}
+		installs = append(installs, install)
+	}
+	sort.SliceStable(installs, func(i, j int) bool {
+		return installs[i].Version.IsNewerThan(installs[j].Version)
+	})
+	return installs, nil
+}
+
+// InstalledAvailableVersions returns a slice of the Versions that
+// have a usable install of their release for the host locally, custom
+// installs and build variants are not taken into account.
+func InstalledAvailableVersions(installsFolder string) ([]Version, error) {
+	installs, err := Installs(installsFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	versions := []Version{}
+	for _, install := range installs {
+		if install.Broken == "" && !install.Info.Custom() && install.Variant == "" && install.Platform.IsHost() {
+			versions = append(versions, install.Version)
+		}
+	}
+	return versions, nil
+}
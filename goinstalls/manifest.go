@@ -0,0 +1,245 @@
+package goinstalls
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MANIFESTFILE is the name of the file, in the install folder, that holds
+// the Manifest of the install.
+const MANIFESTFILE = "MANIFEST.json"
+
+// ManifestFile is a file of the go tree of an install as it was when the
+// install was made.
+type ManifestFile struct {
+	// Path is the path of the file relative to the go tree, slash separated.
+	Path string `json:"path"`
+	// Mode holds the type and permission bits of the file.
+	Mode os.FileMode `json:"mode"`
+	// Size is the size of regular files in bytes.
+	Size int64 `json:"size,omitempty"`
+	// SHA256 is the hex encoded SHA-256 of regular files.
+	SHA256 string `json:"sha256,omitempty"`
+	// Link is the target of symlinks.
+	Link string `json:"link,omitempty"`
+}
+
+// Manifest describes how an install was made and every file of its go
+// tree, it is used to verify the install was not modified since.
+type Manifest struct {
+	// Version is the go version installed.
+	Version string `json:"version"`
+	// SourceURL is where the archive or tree the install was made from
+	// came from, archives taken from the download cache have no url if
+	// they were cached before urls were recorded.
+	SourceURL string `json:"sourceurl,omitempty"`
+	// SHA256 is the verified checksum of the archive the install was made
+	// from, if any.
+	SHA256 string `json:"sha256,omitempty"`
+	// ModuleHash is the verified h1: hash of the toolchain module the
+	// install was made from, if any.
+	ModuleHash string `json:"modulehash,omitempty"`
+	// Bootstrap is the goroot used to build the install, empty if it was
+	// not built or did not need one.
+	Bootstrap string `json:"bootstrap,omitempty"`
+	// BuildSeconds is how long building the install took.
+	BuildSeconds float64 `json:"buildseconds,omitempty"`
+	// Created is the moment the install was completed.
+	Created time.Time `json:"created"`
+	// Files holds the files of the go tree sorted by Path, folders are
+	// not listed.
+	Files []ManifestFile `json:"files"`
+}
+
+// scanTree returns the files of the go tree in goroot sorted by path.
+func scanTree(goroot string) ([]ManifestFile, error) {
+	files := []ManifestFile{}
+	err := filepath.Walk(goroot, func(p string, i os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if i.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(goroot, p)
+		if err != nil {
+			return err
+		}
+		file := ManifestFile{
+			Path: filepath.ToSlash(rel),
+			Mode: i.Mode() & (os.ModeType | os.ModePerm),
+		}
+		switch {
+		case i.Mode().IsRegular():
+			file.Size = i.Size()
+			if file.SHA256, err = hashFile(p); err != nil {
+				return err
+			}
+		case i.Mode()&os.ModeSymlink != 0:
+			if file.Link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "scanning %q", goroot)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// writeManifest records the files of the go tree of the install in
+// installPath into m and writes it in the install folder.
+func writeManifest(installPath string, m Manifest) error {
+	files, err := scanTree(filepath.Join(installPath, "go"))
+	if err != nil {
+		return errors.Wrap(err, "creating install manifest")
+	}
+	m.Files = files
+	marshaled, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "marshaling install manifest")
+	}
+	fileName := filepath.Join(installPath, MANIFESTFILE)
+	return errors.Wrapf(ioutil.WriteFile(fileName, marshaled, 0600), "writing %q", fileName)
+}
+
+// updateManifest records again the file of the go tree of the install in
+// installPath at the slash separated rel, after goworkon changed or removed
+// it, installs with no manifest are left as they are.
+func updateManifest(installPath, rel string) error {
+	m, err := ReadManifest(installPath)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	goroot := filepath.Join(installPath, "go")
+	p := filepath.Join(goroot, filepath.FromSlash(rel))
+	files := []ManifestFile{}
+	i, err := os.Lstat(p)
+	switch {
+	case err == nil:
+		file := ManifestFile{
+			Path: rel,
+			Mode: i.Mode() & (os.ModeType | os.ModePerm),
+			Size: i.Size(),
+		}
+		if file.SHA256, err = hashFile(p); err != nil {
+			return errors.Wrapf(err, "updating install manifest")
+		}
+		files = append(files, file)
+	case !os.IsNotExist(err):
+		return errors.Wrapf(err, "updating install manifest")
+	}
+	for _, f := range m.Files {
+		if f.Path != rel {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	m.Files = files
+	marshaled, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "marshaling install manifest")
+	}
+	fileName := filepath.Join(installPath, MANIFESTFILE)
+	return errors.Wrapf(ioutil.WriteFile(fileName, marshaled, 0600), "writing %q", fileName)
+}
+
+// ReadManifest returns the Manifest of the install in installPath.
+func ReadManifest(installPath string) (Manifest, error) {
+	fileName := filepath.Join(installPath, MANIFESTFILE)
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return Manifest{}, errors.Wrapf(err, "reading %q", fileName)
+	}
+	var m Manifest
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return Manifest{}, errors.Wrapf(err, "unmarshaling %q", fileName)
+	}
+	return m, nil
+}
+
+// Verification is the result of comparing an install with its manifest.
+type Verification struct {
+	// Changed holds the files whose contents, type or permissions changed.
+	Changed []string
+	// Missing holds the files in the manifest that are gone.
+	Missing []string
+	// Extra holds the files that are not in the manifest.
+	Extra []string
+	// VersionMismatch describes how go version differs from the release
+	// installed, empty if it matches or could not be checked.
+	VersionMismatch string
+}
+
+// OK returns true if the install is as it was when it was made.
+func (v Verification) OK() bool {
+	return len(v.Changed) == 0 && len(v.Missing) == 0 && len(v.Extra) == 0 && v.VersionMismatch == ""
+}
+
+// VerifyInstall hashes again the go tree of the install in installPath and
+// compares it with its manifest, for installs of go releases that run on
+// this host go version must also report the release installed.
+// Adopted installs are not managed by goworkon and have no manifest, only
+// their version is checked.
+func VerifyInstall(installPath string) (Verification, error) {
+	info, err := ReadInstallInfo(installPath)
+	if err != nil {
+		return Verification{}, errors.Wrap(err, "the install is not complete")
+	}
+	var result Verification
+	if !info.Adopted() {
+		m, err := ReadManifest(installPath)
+		if err != nil {
+			return Verification{}, errors.Wrap(err, "the install has no manifest, it was made before they were recorded")
+		}
+		files, err := scanTree(filepath.Join(installPath, "go"))
+		if err != nil {
+			return Verification{}, errors.WithStack(err)
+		}
+		current := make(map[string]ManifestFile, len(files))
+		for _, f := range files {
+			current[f.Path] = f
+		}
+		for _, expected := range m.Files {
+			f, ok := current[expected.Path]
+			delete(current, expected.Path)
+			switch {
+			case !ok:
+				result.Missing = append(result.Missing, expected.Path)
+			case f != expected:
+				result.Changed = append(result.Changed, expected.Path)
+			}
+		}
+		for p := range current {
+			result.Extra = append(result.Extra, p)
+		}
+		sort.Strings(result.Extra)
+	}
+	if info.Custom() || !info.Platform().IsHost() {
+		return result, nil
+	}
+	expected, err := VersionFromName(namePrefix + info.Version)
+	if err != nil {
+		return Verification{}, errors.Wrapf(err, "parsing the version of the install")
+	}
+	got, err := GorootVersion(filepath.Join(installPath, "go"))
+	switch {
+	case err != nil:
+		result.VersionMismatch = err.Error()
+	case got != expected:
+		result.VersionMismatch = "go version reports " + got.String() + " instead of " + expected.String()
+	}
+	return result, nil
+}
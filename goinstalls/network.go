@@ -0,0 +1,184 @@
+package goinstalls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NetworkOptions holds the settings that determine how the release feed
+// and files are fetched.
+type NetworkOptions struct {
+	// Mirrors are base urls serving both the release feed and the
+	// release files, as GORELEASESURL does, they are tried in order
+	// before DefaultReleaseSource.
+	Mirrors []string
+	// CABundle is the path to a PEM file with certificates trusted on top
+	// of the system ones.
+	CABundle string
+	// Proxy is the url of the proxy to use, the usual proxy environment
+	// variables are honoured if empty.
+	Proxy string
+	// Timeout limits connecting and waiting for the response headers, the
+	// transfer of the body is not limited since archives are large.
+	Timeout time.Duration
+	// GoProxy are the urls of module proxies, file:// ones included, go is
+	// installed from the toolchain modules they serve instead of the
+	// release archives if any is set.
+	GoProxy []string
+	// SumDB is the checksum database toolchain modules are verified
+	// against, as GOSUMDB is set, DEFAULTSUMDB if empty.
+	SumDB string
+	// TrustGoProxy allows, when SumDB is SUMDBOFF, verifying toolchain
+	// modules against the .ziphash served by the proxies themselves.
+	TrustGoProxy bool
+}
+
+var (
+	// networkMu guards httpClient and mirrorSources, installs can run
+	// concurrently with ConfigureNetwork.
+	networkMu sync.RWMutex
+	// httpClient is the client used for all goinstalls requests.
+	httpClient = http.DefaultClient
+	// mirrorSources are tried in order before DefaultReleaseSource.
+	mirrorSources = []ReleaseSource{}
+	// proxies are the module proxies toolchains are installed from.
+	proxies = []string{}
+	// sumDB is the checksum database toolchains are verified against.
+	sumDB = DEFAULTSUMDB
+	// trustGoProxy is true if the hashes the proxies serve can be used
+	// when sumDB is SUMDBOFF.
+	trustGoProxy = false
+)
+
+// client returns the client to use for requests.
+func client() *http.Client {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return httpClient
+}
+
+// goProxies returns the module proxies to install toolchains from, in
+// order, if empty the release archives are used.
+func goProxies() []string {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return proxies
+}
+
+// checksumDB returns the checksum database to verify toolchains against.
+func checksumDB() string {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return sumDB
+}
+
+// trustsGoProxy returns true if toolchains can be verified against the
+// hashes the proxies serve, see NetworkOptions.TrustGoProxy.
+func trustsGoProxy() bool {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return trustGoProxy
+}
+
+// MirrorSource returns the ReleaseSource of a mirror that serves both
+// the release feed and the release files from baseURL.
+func MirrorSource(baseURL string) ReleaseSource {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return ReleaseSource{FeedURL: baseURL, DownloadURL: baseURL}
+}
+
+// releaseSources returns the sources to try, in order.
+func releaseSources() []ReleaseSource {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return append(append([]ReleaseSource{}, mirrorSources...), DefaultReleaseSource)
+}
+
+// NewHTTPClient returns an http.Client configured according to opts.
+func NewHTTPClient(opts NetworkOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing proxy url %q", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			logger.Warningf("cannot load the system certificates, only %q will be trusted: %v", opts.CABundle, err)
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading CA bundle %q", opts.CABundle)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA bundle %q", opts.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	if opts.Timeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   opts.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = opts.Timeout
+		transport.ResponseHeaderTimeout = opts.Timeout
+	}
+	// module proxies can be folders, ie: a GOMODCACHE/cache/download.
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: transport}, nil
+}
+
+// ConfigureNetwork sets the client and mirrors used by all the requests
+// made by this package.
+func ConfigureNetwork(opts NetworkOptions) error {
+	c, err := NewHTTPClient(opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sources := []ReleaseSource{}
+	for _, mirror := range opts.Mirrors {
+		if _, err := url.Parse(mirror); err != nil {
+			return errors.Wrapf(err, "parsing mirror url %q", mirror)
+		}
+		sources = append(sources, MirrorSource(mirror))
+	}
+	goProxies := []string{}
+	for _, proxy := range opts.GoProxy {
+		// the go command falls back to these, toolchains are only
+		// published in proxies.
+		if proxy == "direct" || proxy == "off" {
+			continue
+		}
+		if _, err := url.Parse(proxy); err != nil {
+			return errors.Wrapf(err, "parsing module proxy url %q", proxy)
+		}
+		goProxies = append(goProxies, proxy)
+	}
+	db := opts.SumDB
+	if db == "" {
+		db = DEFAULTSUMDB
+	}
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	httpClient = c
+	mirrorSources = sources
+	proxies = goProxies
+	sumDB = db
+	trustGoProxy = opts.TrustGoProxy
+	return nil
+}
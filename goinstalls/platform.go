@@ -0,0 +1,99 @@
+package goinstalls
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Platform is the GOOS and GOARCH a go install runs on, empty fields mean
+// those of the host.
+type Platform struct {
+	// OS is the GOOS of the platform.
+	OS string
+	// Arch is the GOARCH of the platform.
+	Arch string
+}
+
+// HostPlatform returns the platform goworkon runs on.
+func HostPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+var platformPartRe = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// Validate returns an error if p does not look like a GOOS and GOARCH.
+func (p Platform) Validate() error {
+	if p.OS != "" && !platformPartRe.MatchString(p.OS) {
+		return errors.Errorf("%q is not a valid os", p.OS)
+	}
+	if p.Arch != "" && !platformPartRe.MatchString(p.Arch) {
+		return errors.Errorf("%q is not a valid architecture", p.Arch)
+	}
+	return nil
+}
+
+// Resolved returns p with the empty fields set to those of the host.
+func (p Platform) Resolved() Platform {
+	host := HostPlatform()
+	if p.OS == "" {
+		p.OS = host.OS
+	}
+	if p.Arch == "" {
+		p.Arch = host.Arch
+	}
+	return p
+}
+
+// IsHost returns true if p is the platform goworkon runs on.
+func (p Platform) IsHost() bool {
+	return p.Resolved() == HostPlatform()
+}
+
+// String returns the os/arch representation of p.
+func (p Platform) String() string {
+	p = p.Resolved()
+	return p.OS + "/" + p.Arch
+}
+
+// InstallName returns the name of the install of v for p, host installs
+// are named after the version and the rest have the platform appended,
+// ie: 1.21.3.linux-arm64.
+func InstallName(v Version, p Platform) string {
+	if p.IsHost() {
+		return v.String()
+	}
+	p = p.Resolved()
+	return v.String() + "." + p.OS + "-" + p.Arch
+}
+
+var platformSuffixRe = regexp.MustCompile(`^(.+)\.([a-z0-9]+)-([a-z0-9]+)$`)
+
+// ParseInstallName returns the version and platform of the install called
+// name, an error is returned if it is not named by InstallName, optionally
+// followed by a build variant as in WithVariant.
+func ParseInstallName(name string) (Version, Platform, error) {
+	p := HostPlatform()
+	versionStr, _ := SplitVariant(name)
+	if parts := platformSuffixRe.FindStringSubmatch(name); parts != nil {
+		versionStr = parts[1]
+		p = Platform{OS: parts[2], Arch: parts[3]}
+	}
+	v, err := VersionFromName(namePrefix + versionStr)
+	if err != nil {
+		return Version{}, Platform{}, errors.Errorf("%q is not named after a go version", name)
+	}
+	return v, p, nil
+}
+
+// RunsOnHost returns an error if the go in the install in installPath
+// cannot be run on this host.
+func RunsOnHost(installPath string) error {
+	out, err := exec.Command(GoBinary(installPath), "version").CombinedOutput()
+	if err != nil {
+		return errors.Errorf("%q cannot run on this host (%s): %v %s", GoBinary(installPath), HostPlatform(), err, out)
+	}
+	return nil
+}
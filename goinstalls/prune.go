@@ -0,0 +1,57 @@
+package goinstalls
+
+import (
+	"time"
+)
+
+// RetentionPolicy determines which of the installs not used by any
+// environment are kept when pruning.
+type RetentionPolicy struct {
+	// KeepPatches is the number of newest installs of each minor, and
+	// platform, that are kept.
+	KeepPatches int
+	// KeepUsedWithin keeps the installs used more recently than this,
+	// zero disables the rule.
+	KeepUsedWithin time.Duration
+}
+
+// Prunable returns the installs that are not in inUse and are not kept by
+// the policy, installs must be sorted from newest to oldest as returned by
+// Installs, installs not named after a go version and adopted ones are never
+// returned and broken ones are only kept if in use. defaultInstall, the
+// install of the default environment, is never returned either.
+func Prunable(installs []Install, inUse map[string]bool, defaultInstall string, policy RetentionPolicy, now time.Time) []Install {
+	perMinor := map[string]int{}
+	prunable := []Install{}
+	for _, install := range installs {
+		if defaultInstall != "" && install.Name == defaultInstall {
+			logger.Infof("not pruning %q, the default environment uses it", install.Name)
+			continue
+		}
+		if _, _, err := ParseInstallName(install.Name); err != nil {
+			continue
+		}
+		if install.Info.Adopted() {
+			continue
+		}
+		if install.Broken != "" {
+			if !inUse[install.Name] {
+				prunable = append(prunable, install)
+			}
+			continue
+		}
+		minor := install.Version.CommonVersionString() + " " + install.Platform.String() + " " + install.Variant
+		perMinor[minor]++
+		if inUse[install.Name] {
+			continue
+		}
+		if perMinor[minor] <= policy.KeepPatches {
+			continue
+		}
+		if policy.KeepUsedWithin > 0 && now.Sub(install.LastUsed) < policy.KeepUsedWithin {
+			continue
+		}
+		prunable = append(prunable, install)
+	}
+	return prunable
+}
@@ -0,0 +1,46 @@
+package goinstalls
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrunableKeepsDefault(t *testing.T) {
+	now := time.Now()
+	// sorted from newest to oldest, as Installs does.
+	installs := []Install{}
+	for _, name := range []string{"1.22.1", "1.21.3", "1.21.0", "1.20.5"} {
+		v, err := VersionFromString(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		installs = append(installs, Install{Name: name, Version: v, LastUsed: now.Add(-365 * 24 * time.Hour)})
+	}
+	installs[3].Broken = "missing go binary"
+	inUse := map[string]bool{"1.22.1": true}
+
+	for _, defaultInstall := range []string{"1.21.3", "1.21.0", "1.20.5"} {
+		t.Run(defaultInstall, func(t *testing.T) {
+			for _, policy := range []RetentionPolicy{{}, {KeepPatches: 1}, {KeepUsedWithin: time.Hour}} {
+				for _, install := range Prunable(installs, inUse, defaultInstall, policy, now) {
+					if install.Name == defaultInstall {
+						t.Errorf("the default install is prunable with %+v", policy)
+					}
+					if inUse[install.Name] {
+						t.Errorf("%s is in use but prunable with %+v", install.Name, policy)
+					}
+				}
+			}
+		})
+	}
+
+	pruned := map[string]bool{}
+	for _, install := range Prunable(installs, inUse, "", RetentionPolicy{}, now) {
+		pruned[install.Name] = true
+	}
+	for _, name := range []string{"1.21.3", "1.21.0", "1.20.5"} {
+		if !pruned[name] {
+			t.Errorf("%s is not prunable without a default environment", name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package goinstalls
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GORELEASESURL is the url of the feed listing all go releases.
+const GORELEASESURL = "https://go.dev/dl/"
+
+// releasesQuery is appended to the feed url to obtain all the releases
+// in json format.
+const releasesQuery = "?mode=json&include=all"
+
+// FileKind is the kind of a downloadable file of a release.
+type FileKind string
+
+const (
+	// KINDARCHIVE is the kind of the prebuilt tar.gz/zip archives.
+	KINDARCHIVE FileKind = "archive"
+	// KINDINSTALLER is the kind of the os specific installers.
+	KINDINSTALLER FileKind = "installer"
+	// KINDSOURCE is the kind of the source archive.
+	KINDSOURCE FileKind = "source"
+	// KINDMODULE is the kind of the toolchain module zips served by
+	// module proxies, see ToolchainReleases.
+	KINDMODULE FileKind = "module"
+)
+
+// ReleaseFile represents one of the downloadable files of a go release.
+type ReleaseFile struct {
+	// Filename is the name of the file, relative to the download url.
+	Filename string `json:"filename"`
+	// OS is the GOOS this file is for, empty for source.
+	OS string `json:"os"`
+	// Arch is the GOARCH this file is for, empty for source.
+	Arch string `json:"arch"`
+	// Version is the go version of this file, ie: go1.7.3.
+	Version string `json:"version"`
+	// SHA256 is the hex encoded SHA-256 of the file.
+	SHA256 string `json:"sha256"`
+	// Size is the size in bytes of the file.
+	Size int64 `json:"size"`
+	// Kind is the kind of file.
+	Kind FileKind `json:"kind"`
+}
+
+// Release represents a go release as listed in the release feed.
+type Release struct {
+	// Version is the name of the release, ie: go1.7.3.
+	Version string `json:"version"`
+	// Stable is true if this is not a beta or rc.
+	Stable bool `json:"stable"`
+	// Files holds all the files of this release.
+	Files []ReleaseFile `json:"files"`
+}
+
+// File returns the file of the given kind for goos and goarch, source
+// files match with empty goos and goarch.
+func (r Release) File(kind FileKind, goos, goarch string) (ReleaseFile, bool) {
+	for _, f := range r.Files {
+		if f.Kind != kind || f.OS != goos || f.Arch != goarch {
+			continue
+		}
+		// windows archives are published both as zip and tar.gz.
+		if kind == KINDARCHIVE && !strings.HasSuffix(f.Filename, binSuffix) {
+			continue
+		}
+		return f, true
+	}
+	return ReleaseFile{}, false
+}
+
+// ReleaseSource knows where to find the go release feed and the files
+// it lists.
+type ReleaseSource struct {
+	// FeedURL is the base url of the json release feed.
+	FeedURL string
+	// DownloadURL is the url the release files are downloaded from.
+	DownloadURL string
+}
+
+// DefaultReleaseSource is the ReleaseSource used to find and download
+// go versions, after any mirror set with ConfigureNetwork.
+var DefaultReleaseSource = ReleaseSource{
+	FeedURL:     GORELEASESURL,
+	DownloadURL: GODLURL,
+}
+
+// Releases returns all the releases listed in the feed.
+func (s ReleaseSource) Releases() ([]Release, error) {
+	response, err := client().Get(s.FeedURL + releasesQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching the release feed")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching the release feed: %s", response.Status)
+	}
+	var releases []Release
+	if err := json.NewDecoder(response.Body).Decode(&releases); err != nil {
+		return nil, errors.Wrap(err, "decoding the release feed")
+	}
+	return releases, nil
+}
+
+// allReleases returns the releases listed in the feed of the first
+// source that can provide it.
+func allReleases() ([]Release, error) {
+	failures := []string{}
+	for _, source := range releaseSources() {
+		releases, err := source.Releases()
+		if err == nil {
+			return releases, nil
+		}
+		logger.Warningf("cannot use %q: %v", source.FeedURL, err)
+		failures = append(failures, err.Error())
+	}
+	return nil, errors.Errorf("no release feed could be fetched: %s", strings.Join(failures, "; "))
+}
+
+// FileURL returns the url from where the given file can be downloaded.
+func (s ReleaseSource) FileURL(f ReleaseFile) string {
+	return s.DownloadURL + f.Filename
+}
@@ -0,0 +1,343 @@
+package goinstalls
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/perrito666/goworkon/progress"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TOOLCHAINMODULE is the module go toolchains are published as in
+	// module proxies, since go 1.21.
+	TOOLCHAINMODULE = "golang.org/toolchain"
+	// DEFAULTSUMDB is the checksum database toolchain modules are
+	// verified against when none is configured.
+	DEFAULTSUMDB = "sum.golang.org"
+	// SUMDBOFF disables the checksum database, toolchain modules are then
+	// verified against the .ziphash served by the proxy, if it is trusted.
+	SUMDBOFF = "off"
+
+	// toolchainCachePrefix is prepended to the name of toolchain module
+	// zips in the download cache.
+	toolchainCachePrefix = "toolchain-"
+)
+
+// toolchainVersionRe matches the versions of toolchain modules, ie:
+// v0.0.1-go1.21.3.linux-amd64.
+var toolchainVersionRe = regexp.MustCompile(`^v0\.0\.1-(go.+)\.([a-z0-9]+)-([a-z0-9]+)$`)
+
+// proxyGet fetches the given path, relative to the module proxy in proxy,
+// and returns its contents.
+func proxyGet(proxy, path string) ([]byte, error) {
+	url := strings.TrimSuffix(proxy, "/") + "/" + path
+	response, err := client().Get(url)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching %q: %s", url, response.Status)
+	}
+	contents, err := ioutil.ReadAll(response.Body)
+	return contents, errors.Wrapf(err, "fetching %q", url)
+}
+
+// ToolchainReleases returns the releases that can be installed from the
+// toolchain modules listed by the first of the configured module proxies
+// that can list them, each has a file of KINDMODULE per platform.
+func ToolchainReleases() (map[Version]Release, error) {
+	failures := []string{}
+	for _, proxy := range goProxies() {
+		list, err := proxyGet(proxy, TOOLCHAINMODULE+"/@v/list")
+		if err != nil {
+			logger.Warningf("cannot use %q: %v", proxy, err)
+			failures = append(failures, err.Error())
+			continue
+		}
+		releases := map[Version]Release{}
+		for _, line := range strings.Fields(string(list)) {
+			parts := toolchainVersionRe.FindStringSubmatch(line)
+			if parts == nil {
+				logger.Debugf("skipping toolchain %q", line)
+				continue
+			}
+			v, err := VersionFromName(parts[1])
+			if err != nil {
+				logger.Debugf("skipping toolchain %q: %v", line, err)
+				continue
+			}
+			release := releases[v]
+			release.Version = parts[1]
+			release.Stable = v.IsRelease()
+			release.Files = append(release.Files, ReleaseFile{
+				Filename: line + ".zip",
+				OS:       parts[2],
+				Arch:     parts[3],
+				Version:  parts[1],
+				Kind:     KINDMODULE,
+			})
+			releases[v] = release
+		}
+		return releases, nil
+	}
+	return nil, errors.Errorf("no module proxy could list the go toolchains: %s", strings.Join(failures, "; "))
+}
+
+// sumDBLookup returns the url where the checksum database record of
+// module is looked up, directly from the database and never through the
+// module proxies, which serve the zips checked against it.
+// The database is configured as GOSUMDB is, ie: sum.golang.org or
+// sum.golang.org+<key> https://sum.golang.google.cn.
+func sumDBLookup(db, module string) string {
+	fields := strings.Fields(db)
+	name := strings.SplitN(fields[0], "+", 2)[0]
+	direct := "https://" + name
+	if len(fields) > 1 {
+		direct = strings.TrimSuffix(fields[1], "/")
+	}
+	return direct + "/lookup/" + module
+}
+
+// toolchainSum returns the h1: hash of the toolchain module version
+// modVersion as recorded in the checksum database or, if it is off and the
+// proxies are trusted, in the .ziphash served by the proxies.
+// The record is fetched from the database itself, its signed tree is not
+// verified, so TLS is what authenticates it.
+func toolchainSum(modVersion string) (string, error) {
+	module := TOOLCHAINMODULE + "@" + modVersion
+	db := checksumDB()
+	failures := []string{}
+	if db == SUMDBOFF {
+		if !trustsGoProxy() {
+			return "", errors.Errorf("refusing to install %q, the checksum database is off and a proxy could serve a forged toolchain along with its hash, set trustgoproxy to true to trust the proxies",
+				module)
+		}
+		for _, proxy := range goProxies() {
+			contents, err := proxyGet(proxy, TOOLCHAINMODULE+"/@v/"+modVersion+".ziphash")
+			if err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			return strings.TrimSpace(string(contents)), nil
+		}
+		return "", errors.Errorf("refusing to install %q, the checksum database is off and no proxy has its .ziphash: %s",
+			module, strings.Join(failures, "; "))
+	}
+	url := sumDBLookup(db, module)
+	response, err := client().Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "refusing to install %q, the checksum database cannot be reached", module)
+	}
+	contents, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil || response.StatusCode != http.StatusOK {
+		return "", errors.Errorf("refusing to install %q, fetching %q: %s %v", module, url, response.Status, err)
+	}
+	// the record holds go.sum lines: <module> <version>[/go.mod] <hash>.
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == TOOLCHAINMODULE && fields[1] == modVersion {
+			return fields[2], nil
+		}
+	}
+	return "", errors.Errorf("refusing to install %q, %q has no hash for it", module, url)
+}
+
+// hashZip returns the h1: hash, as recorded in go.sum files, of the module
+// zip in zipPath.
+func hashZip(zipPath string) (string, error) {
+	z, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "opening %q", zipPath)
+	}
+	defer z.Close()
+	files := append([]*zip.File{}, z.File...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	summary := sha256.New()
+	for _, f := range files {
+		if strings.Contains(f.Name, "\n") {
+			return "", errors.Errorf("%q holds a file name with a new line", zipPath)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return "", errors.Wrapf(err, "opening %q in %q", f.Name, zipPath)
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", errors.Wrapf(err, "hashing %q in %q", f.Name, zipPath)
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), f.Name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// verifyZip returns an error if the module zip in zipPath does not hash to
+// expected.
+func verifyZip(zipPath, expected string) error {
+	sum, err := hashZip(zipPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if sum != expected {
+		return errors.Errorf("checksum mismatch for %q: expected %s got %s", filepath.Base(zipPath), expected, sum)
+	}
+	return nil
+}
+
+// downloadToolchain returns the path to the given toolchain module zip of
+// go v in the download cache, the url it was downloaded from and its
+// verified hash, it is downloaded from the first proxy that can provide it
+// if it is not cached already.
+func downloadToolchain(v Version, file ReleaseFile, opts InstallOptions) (string, string, string, error) {
+	if opts.CacheDir == "" {
+		return "", "", "", errors.New("no download cache folder specified")
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0700); err != nil {
+		return "", "", "", errors.Wrapf(err, "creating download cache %q", opts.CacheDir)
+	}
+	modVersion := strings.TrimSuffix(file.Filename, ".zip")
+	expected, err := toolchainSum(modVersion)
+	if err != nil {
+		return "", "", "", errors.WithStack(err)
+	}
+	zipPath := filepath.Join(opts.CacheDir, toolchainCachePrefix+file.Filename)
+	// the cache is looked up with the lock held, another install might
+	// have just downloaded the zip.
+	unlock, err := lockCached(zipPath)
+	if err != nil {
+		return "", "", "", errors.WithStack(err)
+	}
+	defer unlock()
+	sourceFile := zipPath + cachedSourceSuffix
+	if _, err := os.Stat(zipPath); err == nil {
+		err := verifyZip(zipPath, expected)
+		if err == nil {
+			logger.Debugf("using cached %q", zipPath)
+			sourceURL, _ := ioutil.ReadFile(sourceFile)
+			return zipPath, strings.TrimSpace(string(sourceURL)), expected, nil
+		}
+		logger.Warningf("discarding cached %q: %v", zipPath, err)
+		if err := os.Remove(zipPath); err != nil {
+			return "", "", "", errors.Wrapf(err, "removing corrupt %q", zipPath)
+		}
+	}
+
+	partial := zipPath + partialSuffix
+	failures := []string{}
+	for _, proxy := range goProxies() {
+		url := strings.TrimSuffix(proxy, "/") + "/" + TOOLCHAINMODULE + "/@v/" + file.Filename
+		task := progress.Start(opts.Progress, InstallName(v, opts.Platform), progress.STAGEDOWNLOAD, 0)
+		err := fetch(url, partial, task)
+		if err == nil {
+			if err = verifyZip(partial, expected); err != nil {
+				// there is no point in resuming a corrupt download.
+				os.Remove(partial)
+			}
+		}
+		task.Done(err)
+		if err != nil {
+			logger.Warningf("cannot download %q: %v", url, err)
+			failures = append(failures, err.Error())
+			continue
+		}
+		if err := os.Rename(partial, zipPath); err != nil {
+			return "", "", "", errors.Wrapf(err, "moving %q into the download cache", file.Filename)
+		}
+		if err := ioutil.WriteFile(sourceFile, []byte(url+"\n"), 0600); err != nil {
+			return "", "", "", errors.Wrapf(err, "writing %q", sourceFile)
+		}
+		return zipPath, url, expected, nil
+	}
+	return "", "", "", errors.Errorf("refusing to install %q, no proxy could provide it: %s",
+		file.Filename, strings.Join(failures, "; "))
+}
+
+// toolchainPerm returns the permissions of the file in path, relative to
+// the go tree, module zips do not keep them so the commands are made
+// executable as the go command does when it switches toolchains.
+func toolchainPerm(path string) os.FileMode {
+	if strings.HasPrefix(path, "bin/") || strings.HasPrefix(path, "pkg/tool/") {
+		return 0755
+	}
+	return 0644
+}
+
+// unzipToolchain extracts the toolchain module zip of version modVersion
+// in zipPath as the go tree of targetPath, progress is reported in
+// uncompressed bytes.
+func unzipToolchain(zipPath, modVersion, targetPath string, task *progress.Task) error {
+	z, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return errors.Wrapf(err, "opening %q", zipPath)
+	}
+	defer z.Close()
+	root, err := filepath.Abs(filepath.Join(targetPath, "go"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var total int64
+	for _, f := range z.File {
+		total += int64(f.UncompressedSize64)
+	}
+	task.SetTotal(total)
+	prefix := TOOLCHAINMODULE + "@" + modVersion + "/"
+	for _, f := range z.File {
+		// folders are created as needed.
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if !strings.HasPrefix(f.Name, prefix) {
+			return errors.Errorf("refusing to extract %q, it is not part of %s", f.Name, prefix)
+		}
+		rel := strings.TrimPrefix(f.Name, prefix)
+		p, err := safeJoin(root, filepath.FromSlash(rel))
+		if err != nil {
+			return errors.Wrap(err, "refusing to extract")
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return errors.Wrapf(err, "creating folder for %q", p)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return errors.Wrapf(err, "opening %q in %q", f.Name, zipPath)
+		}
+		err = writeFile(task.Reader(r), p, toolchainPerm(rel))
+		r.Close()
+		if err != nil {
+			return errors.Wrap(err, "running extract")
+		}
+	}
+	return nil
+}
+
+// installToolchain downloads the toolchain module file of go v, verifies it
+// and extracts it into targetPath, where it came from and its hash are
+// recorded in m.
+func installToolchain(v Version, file ReleaseFile, targetPath string, opts InstallOptions, m *Manifest) error {
+	zipPath, sourceURL, sum, err := downloadToolchain(v, file, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.SourceURL = sourceURL
+	m.ModuleHash = sum
+	task := progress.Start(opts.Progress, InstallName(v, opts.Platform), progress.STAGEEXTRACT, 0)
+	err = unzipToolchain(zipPath, strings.TrimSuffix(file.Filename, ".zip"), targetPath, task)
+	task.Done(err)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,249 @@
+package goinstalls
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// within returns true if path is root or is inside it, both must be clean.
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(os.PathSeparator))
+}
+
+// safeJoin joins name to root and returns an error if the result is not
+// inside root.
+func safeJoin(root, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", errors.Errorf("%q is an absolute path", name)
+	}
+	p := filepath.Join(root, name)
+	if !within(root, p) {
+		return "", errors.Errorf("%q points outside of %q", name, root)
+	}
+	return p, nil
+}
+
+// ensureNoSymlinkParents returns an error if any of the folders between
+// root and p is a symlink, writing through them could escape root.
+func ensureNoSymlinkParents(root, p string) error {
+	for dir := filepath.Dir(p); within(root, dir) && dir != root; dir = filepath.Dir(dir) {
+		i, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if i.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("%q is inside the symlink %q", p, dir)
+		}
+	}
+	return nil
+}
+
+// ensureSymlinkTarget returns an error if the symlink p, pointing to
+// linkname, would point outside of root or through another symlink, whose
+// target can change where it points without it being seen here. Going up
+// from a path that does not exist yet is refused too, it could become a
+// symlink later.
+func ensureSymlinkTarget(root, p, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return errors.Errorf("symlink %q points to the absolute path %q", p, linkname)
+	}
+	target := filepath.Dir(p)
+	missing := false
+	for _, elem := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if missing {
+				return errors.Errorf("symlink %q goes up from %q, which does not exist", p, target)
+			}
+			target = filepath.Dir(target)
+		default:
+			target = filepath.Join(target, elem)
+		}
+		if !within(root, target) {
+			return errors.Errorf("symlink %q points outside of %q", p, root)
+		}
+		if missing {
+			continue
+		}
+		i, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			missing = true
+			continue
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if i.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("symlink %q points through the symlink %q", p, target)
+		}
+	}
+	return nil
+}
+
+// ensureDir returns an error if p exists and is not a folder, folders are
+// created through MkdirAll and changed in place, which follows symlinks.
+func ensureDir(p string) error {
+	i, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !i.IsDir() {
+		return errors.Errorf("%q already exists and is not a folder", p)
+	}
+	return nil
+}
+
+// removeExisting removes whatever is in p so it is not followed or
+// overwritten in place, folders are left untouched.
+func removeExisting(p string) error {
+	i, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if i.IsDir() {
+		return errors.Errorf("%q already exists as a folder", p)
+	}
+	return errors.WithStack(os.Remove(p))
+}
+
+// writeFile writes the contents of r, ie: the current entry of a tar, into
+// p with the given permissions.
+func writeFile(r io.Reader, p string, perm os.FileMode) error {
+	fp, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return errors.Wrapf(err, "creating %q", p)
+	}
+	defer fp.Close()
+	if _, err := io.Copy(fp, r); err != nil {
+		return errors.Wrapf(err, "writing %q", p)
+	}
+	// the umask might have changed the permissions.
+	return errors.Wrapf(fp.Chmod(perm), "setting permissions of %q", p)
+}
+
+// untar extracts tarFile into targetPath, entries that would end up outside
+// of targetPath make it fail. Folders, regular files, symlinks and hardlinks
+// are extracted with their permission bits and modification times, any other
+// kind of entry is skipped, ownership is not kept as the files belong to
+// whoever installs go.
+func untar(tarFile *tar.Reader, targetPath string) error {
+	logger.Debugf("extracting into %q", targetPath)
+	root, err := filepath.Abs(targetPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return errors.Wrapf(err, "creating %q", root)
+	}
+	// folder times are set at the end, extracting into them changes them.
+	dirTimes := map[string]time.Time{}
+	for {
+		h, err := tarFile.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "uncompressing headers")
+		}
+		p, err := safeJoin(root, h.Name)
+		if err != nil {
+			return errors.Wrap(err, "refusing to extract")
+		}
+		if err := ensureNoSymlinkParents(root, p); err != nil {
+			return errors.Wrap(err, "refusing to extract")
+		}
+		logger.Tracef(p)
+		perm := h.FileInfo().Mode().Perm()
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := ensureDir(p); err != nil {
+				return errors.Wrap(err, "refusing to extract")
+			}
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return errors.Wrapf(err, "creating folder %q", p)
+			}
+			// we need to be able to write the folder contents.
+			if err := os.Chmod(p, perm|0700); err != nil {
+				return errors.Wrapf(err, "setting permissions of %q", p)
+			}
+			dirTimes[p] = h.ModTime
+			continue
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return errors.Wrapf(err, "creating folder for %q", p)
+			}
+			if err := removeExisting(p); err != nil {
+				return errors.Wrapf(err, "extracting %q", h.Name)
+			}
+			if err := writeFile(tarFile, p, perm); err != nil {
+				return errors.Wrap(err, "running extract")
+			}
+		case tar.TypeSymlink:
+			if err := ensureSymlinkTarget(root, p, h.Linkname); err != nil {
+				return errors.Wrap(err, "refusing to extract")
+			}
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return errors.Wrapf(err, "creating folder for %q", p)
+			}
+			if err := removeExisting(p); err != nil {
+				return errors.Wrapf(err, "extracting %q", h.Name)
+			}
+			if err := os.Symlink(h.Linkname, p); err != nil {
+				return errors.Wrapf(err, "creating symlink %q", p)
+			}
+			// symlink times cannot be set portably.
+			continue
+		case tar.TypeLink:
+			target, err := safeJoin(root, h.Linkname)
+			if err != nil {
+				return errors.Wrapf(err, "refusing to extract hardlink %q", h.Name)
+			}
+			if err := ensureNoSymlinkParents(root, target); err != nil {
+				return errors.Wrapf(err, "refusing to extract hardlink %q", h.Name)
+			}
+			if i, err := os.Lstat(target); err != nil || !i.Mode().IsRegular() {
+				return errors.Errorf("hardlink %q must point to a file extracted before it", h.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return errors.Wrapf(err, "creating folder for %q", p)
+			}
+			if err := removeExisting(p); err != nil {
+				return errors.Wrapf(err, "extracting %q", h.Name)
+			}
+			if err := os.Link(target, p); err != nil {
+				return errors.Wrapf(err, "creating hardlink %q", p)
+			}
+			continue
+		default:
+			logger.Debugf("skipping %q, entries of type %q are not extracted", h.Name, string(h.Typeflag))
+			continue
+		}
+		if err := os.Chtimes(p, h.ModTime, h.ModTime); err != nil {
+			return errors.Wrapf(err, "setting times of %q", p)
+		}
+	}
+	for dir, modTime := range dirTimes {
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			return errors.Wrapf(err, "setting times of %q", dir)
+		}
+	}
+	return nil
+}
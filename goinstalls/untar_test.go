@@ -0,0 +1,171 @@
+package goinstalls
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// entry is an entry of a tarball built by makeTar.
+type entry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	linkname string
+	body     string
+}
+
+func makeTar(t *testing.T, entries []entry) *tar.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     e.mode,
+			Linkname: e.linkname,
+			Size:     int64(len(e.body)),
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tar.NewReader(&buf)
+}
+
+// untarScratch returns a folder to extract into, inside another one that
+// must be left untouched, and a function that fails the test if it was not.
+func untarScratch(t *testing.T) (string, func()) {
+	outside, err := ioutil.TempDir("", "goworkon-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(outside, 0700); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(outside, "root")
+	return root, func() {
+		defer os.RemoveAll(outside)
+		i, err := os.Stat(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.Mode().Perm() != 0700 {
+			t.Errorf("the folder outside the target changed its permissions to %v", i.Mode().Perm())
+		}
+		entries, err := ioutil.ReadDir(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("files were written outside the target: %v", entries)
+		}
+	}
+}
+
+func TestUntarExtracts(t *testing.T) {
+	root, check := untarScratch(t)
+	defer check()
+	err := untar(makeTar(t, []entry{
+		{name: "go/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "go/bin/go", typeflag: tar.TypeReg, mode: 0755, body: "binary"},
+		{name: "go/lib/link", typeflag: tar.TypeSymlink, linkname: "../bin/go"},
+		{name: "go/hard", typeflag: tar.TypeLink, linkname: "go/bin/go"},
+	}), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"go/bin/go", "go/lib/link", "go/hard"} {
+		contents, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != "binary" {
+			t.Errorf("%s holds %q", name, contents)
+		}
+	}
+}
+
+func TestUntarRefusesEscapes(t *testing.T) {
+	for _, test := range []struct {
+		about   string
+		entries []entry
+	}{{
+		about: "absolute path",
+		entries: []entry{
+			{name: "/etc/passwd", typeflag: tar.TypeReg, mode: 0644},
+		},
+	}, {
+		about: "parent path",
+		entries: []entry{
+			{name: "../escaped", typeflag: tar.TypeReg, mode: 0644},
+		},
+	}, {
+		about: "symlink outside",
+		entries: []entry{
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "../"},
+		},
+	}, {
+		about: "absolute symlink",
+		entries: []entry{
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "/tmp"},
+		},
+	}, {
+		about: "file through a symlink",
+		entries: []entry{
+			{name: "dir/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "dir"},
+			{name: "link/file", typeflag: tar.TypeReg, mode: 0644},
+		},
+	}, {
+		about: "folder over a symlink",
+		entries: []entry{
+			{name: "dir/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "dir"},
+			{name: "link/", typeflag: tar.TypeDir, mode: 0777},
+		},
+	}, {
+		about: "symlink through a symlink",
+		entries: []entry{
+			{name: "p/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "p/q/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "p/q/d", typeflag: tar.TypeSymlink, linkname: "../.."},
+			{name: "e", typeflag: tar.TypeSymlink, linkname: "p/q/d/.."},
+			{name: "e/", typeflag: tar.TypeDir, mode: 0777},
+		},
+	}, {
+		about: "symlink going up from a missing path",
+		entries: []entry{
+			{name: "x", typeflag: tar.TypeSymlink, linkname: "y/.."},
+			{name: "y", typeflag: tar.TypeSymlink, linkname: "."},
+		},
+	}, {
+		about: "hardlink outside",
+		entries: []entry{
+			{name: "hard", typeflag: tar.TypeLink, linkname: "../outside"},
+		},
+	}, {
+		about: "hardlink to a symlink",
+		entries: []entry{
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "hard", typeflag: tar.TypeLink, linkname: "link"},
+		},
+	}} {
+		t.Run(test.about, func(t *testing.T) {
+			root, check := untarScratch(t)
+			defer check()
+			if err := untar(makeTar(t, test.entries), root); err == nil {
+				t.Error("the tarball was extracted")
+			}
+		})
+	}
+}
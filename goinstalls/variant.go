@@ -0,0 +1,132 @@
+package goinstalls
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VARIANTSEPARATOR separates the go version from the build variant in the
+// names of variant installs, ie: 1.22.3+v3.
+const VARIANTSEPARATOR = "+"
+
+// variantNameRe matches valid build variant names.
+var variantNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// ValidVariantName returns an error if name cannot name a build variant.
+func ValidVariantName(name string) error {
+	if !variantNameRe.MatchString(name) {
+		return errors.Errorf("%q is not a valid build variant name, use letters, numbers, - and _", name)
+	}
+	return nil
+}
+
+// Variant is a recipe to build go from source other than the vanilla
+// toolchain, such as with GOEXPERIMENT flags or other GOAMD64 defaults.
+type Variant struct {
+	// Name names the variant, it is appended to the names of its installs.
+	Name string `json:"name,omitempty"`
+	// Env holds the variables set for the build, make.bash makes some of
+	// them, like GOAMD64 or GOEXPERIMENT, the defaults of the toolchain.
+	Env map[string]string `json:"env,omitempty"`
+	// Patches are the paths of patch files applied, in order, to the go
+	// tree before building it.
+	Patches []string `json:"patches,omitempty"`
+}
+
+// environ returns Env in KEY=value form, sorted.
+func (v Variant) environ() []string {
+	vars := make([]string, 0, len(v.Env))
+	for name, value := range v.Env {
+		vars = append(vars, name+"="+value)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// String returns the build recipe of the variant.
+func (v Variant) String() string {
+	recipe := v.environ()
+	for _, patch := range v.Patches {
+		recipe = append(recipe, "patch "+filepath.Base(patch))
+	}
+	if len(recipe) == 0 {
+		return "vanilla"
+	}
+	return strings.Join(recipe, " ")
+}
+
+// WithVariant returns the name of the install of the build variant
+// variant of the install called installName, installName if variant is
+// empty.
+func WithVariant(installName, variant string) string {
+	if variant == "" {
+		return installName
+	}
+	return installName + VARIANTSEPARATOR + variant
+}
+
+// SplitVariant returns name without the build variant and the variant, if
+// any, see WithVariant.
+func SplitVariant(name string) (string, string) {
+	i := strings.LastIndex(name, VARIANTSEPARATOR)
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+len(VARIANTSEPARATOR):]
+}
+
+// applyPatches applies the patches of variant to the go tree in goroot.
+func applyPatches(goroot string, variant Variant) error {
+	for _, patch := range variant.Patches {
+		cmd := exec.Command("git", "apply", "--whitespace=nowarn", patch)
+		cmd.Dir = goroot
+		// git must not look for a repository above the tree.
+		cmd.Env = withEnv(os.Environ(), "GIT_CEILING_DIRECTORIES="+filepath.Dir(goroot))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return errors.Errorf("applying patch %q of build variant %q: %v: %s",
+				patch, variant.Name, err, strings.TrimSpace(stderr.String()))
+		}
+		logger.Debugf("applied patch %q to %q", patch, goroot)
+	}
+	return nil
+}
+
+// ensureVariantBuild returns an error if the build variant in opts cannot
+// be installed for platform.
+func ensureVariantBuild(platform Platform, opts InstallOptions) error {
+	if !opts.FromSource {
+		return errors.Errorf("build variant %q can only be built from source", opts.Variant.Name)
+	}
+	if !platform.IsHost() {
+		return errors.Errorf("build variant %q cannot be installed for %s, only for this host", opts.Variant.Name, platform)
+	}
+	for _, patch := range opts.Variant.Patches {
+		if _, err := os.Stat(patch); err != nil {
+			return errors.Wrapf(err, "finding patch of build variant %q", opts.Variant.Name)
+		}
+	}
+	return nil
+}
+
+// ParseVariantConstraint parses a constraint, as ParseConstraint does,
+// optionally followed by a build variant as in WithVariant, ie: 1.22+v3,
+// and returns it along with the variant name, if any.
+func ParseVariantConstraint(s string) (Constraint, string, error) {
+	constraint, variant := SplitVariant(s)
+	if variant != "" || strings.HasSuffix(s, VARIANTSEPARATOR) {
+		if err := ValidVariantName(variant); err != nil {
+			return Constraint{}, "", errors.WithStack(err)
+		}
+	}
+	c, err := ParseConstraint(constraint)
+	return c, variant, errors.WithStack(err)
+}
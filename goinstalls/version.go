@@ -2,16 +2,28 @@ package goinstalls
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
-	"strings"
 
 	"github.com/pkg/errors"
 )
 
-// Version represents a go version, since its intended for
-// the source of released versions, no suffix/build is supported.
-// TODO (perrito666) accept build/rc and figure how to determine
-// newer.
+const (
+	// PREBETA is the Pre of beta versions, ie: 1.9beta1.
+	PREBETA = "beta"
+	// PRERC is the Pre of release candidates, ie: 1.21rc2.
+	PRERC = "rc"
+
+	// namePrefix is the prefix go uses for version names, ie: go1.7.3.
+	namePrefix = "go"
+	// firstExplicitPatchMinor is the first minor released as x.y.0, older
+	// releases where named x.y and x.y was the language version too.
+	firstExplicitPatchMinor = 21
+)
+
+// Version represents a go version, it can be either a release (1.21.0),
+// a pre-release (1.21rc2) or a language version (1.21) that names the
+// family of releases of a given minor.
 type Version struct {
 	// Major is the first tier of a version number.
 	Major int
@@ -19,29 +31,83 @@ type Version struct {
 	Minor int
 	// Patch is the final tier of a version number.
 	Patch int
+	// Pre is PREBETA or PRERC for pre-releases, empty otherwise.
+	Pre string
+	// PreNumber is the number of the pre-release, ie: 2 in 1.21rc2.
+	PreNumber int
+	// Language is true when this is a language version, Patch and Pre
+	// are meaningless then.
+	Language bool
 }
 
-// IsNewerThan returns true if the passed version is
-// older than the current one.
-func (v Version) IsNewerThan(ver Version) bool {
-	if v.Major > ver.Major {
-		return true
-	}
-	if v.Major < ver.Major {
-		return false
+// stage returns the position of the version in its minor's lifecycle,
+// the language version goes first, then betas, rcs and releases.
+func (v Version) stage() int {
+	switch {
+	case v.Language:
+		return 0
+	case v.Pre == PREBETA:
+		return 1
+	case v.Pre == PRERC:
+		return 2
 	}
+	return 3
+}
 
-	if v.Minor > ver.Minor {
-		return true
+func compareInts(a, b int) int {
+	if a > b {
+		return 1
 	}
-	if v.Minor < ver.Minor {
-		return false
+	if a < b {
+		return -1
 	}
+	return 0
+}
 
-	if v.Patch > ver.Patch {
-		return true
+// Compare returns -1, 0 or 1 if the current version is older, the same
+// or newer than the passed one.
+func (v Version) Compare(ver Version) int {
+	if c := compareInts(v.Major, ver.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, ver.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.stage(), ver.stage()); c != 0 {
+		return c
 	}
-	return false
+	if v.Pre != "" {
+		return compareInts(v.PreNumber, ver.PreNumber)
+	}
+	if v.Language {
+		return 0
+	}
+	return compareInts(v.Patch, ver.Patch)
+}
+
+// IsNewerThan returns true if the passed version is
+// older than the current one.
+func (v Version) IsNewerThan(ver Version) bool {
+	return v.Compare(ver) > 0
+}
+
+// Versions is a slice of Version that implements sort.Interface, it sorts
+// from oldest to newest, use sort.Reverse for newest first.
+type Versions []Version
+
+// Len implements sort.Interface.
+func (vs Versions) Len() int {
+	return len(vs)
+}
+
+// Less implements sort.Interface.
+func (vs Versions) Less(i, j int) bool {
+	return vs[i].Compare(vs[j]) < 0
+}
+
+// Swap implements sort.Interface.
+func (vs Versions) Swap(i, j int) {
+	vs[i], vs[j] = vs[j], vs[i]
 }
 
 // SameVersion returns true if the passed version is
@@ -50,76 +116,106 @@ func (v Version) SameVersion(ver Version) bool {
 	return v.Major == ver.Major && v.Minor == ver.Minor
 }
 
+// IsRelease returns true if the version is a final release.
+func (v Version) IsRelease() bool {
+	return !v.Language && v.Pre == ""
+}
+
 // CommonVersionString returns a string representing the
 // Major.Minor version of the version.
 func (v Version) CommonVersionString() string {
 	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
 }
 
+// Lang returns the language version of the version.
+func (v Version) Lang() Version {
+	return Version{
+		Major:    v.Major,
+		Minor:    v.Minor,
+		Language: true,
+	}
+}
+
+// legacyRelease returns true for x.y.0 releases that where named x.y.
+func (v Version) legacyRelease() bool {
+	return v.Major == 1 && v.Minor < firstExplicitPatchMinor
+}
+
 // String returns the string representation of this Version.
 func (v Version) String() string {
-	if v.Patch == 0 {
+	switch {
+	case v.Language:
+		return v.CommonVersionString()
+	case v.Pre != "":
+		return fmt.Sprintf("%s%s%d", v.CommonVersionString(), v.Pre, v.PreNumber)
+	case v.Patch == 0 && v.legacyRelease():
+		// the first release was just go1.
+		if v.Minor == 0 {
+			return fmt.Sprintf("%d", v.Major)
+		}
 		return v.CommonVersionString()
 	}
 	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
 }
 
-type downloadable struct {
-	Key            string `xml:"Key"`
-	Generation     string `xml:"Generation"`
-	MetaGeneration int64  `xml:"MetaGeneration"`
-	LastModified   string `xml:"LastModified"`
-	ETag           string `xml:"ETag"`
-	Size           int64  `xml:"Size"`
-	Owner          string `xml:"Owner"`
+// Name returns the go prefixed name of the version, as used in release
+// and file names, ie: go1.7.3.
+func (v Version) Name() string {
+	return namePrefix + v.String()
 }
 
-type golangDownloadsPageContents struct {
-	Name     string         `xml:"Name"`
-	Contents []downloadable `xml:"Contents"`
-}
+var versionRe = regexp.MustCompile(`^(?:go)?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:(beta|rc)(\d+))?$`)
 
-type golangDownloadsPage struct {
-	ListBucketResult golangDownloadsPageContents `xml:"ListBucketResult"`
+// parseVersion parses versionStr, the go prefix is optional, versions
+// without patch are returned as language versions.
+func parseVersion(versionStr string) (Version, error) {
+	parts := versionRe.FindStringSubmatch(versionStr)
+	if parts == nil {
+		return Version{}, errors.Errorf("%q is not a valid version string", versionStr)
+	}
+	number := func(s string) int {
+		// the regexp ensures these are numbers.
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	v := Version{
+		Major:     number(parts[1]),
+		Minor:     number(parts[2]),
+		Patch:     number(parts[3]),
+		Pre:       parts[4],
+		PreNumber: number(parts[5]),
+	}
+	hasMinor, hasPatch := parts[2] != "", parts[3] != ""
+	if !hasMinor && (v.Major != 1 || v.Pre != "") {
+		return Version{}, errors.Errorf("%q is missing the minor version", versionStr)
+	}
+	if hasPatch && v.Pre != "" {
+		return Version{}, errors.Errorf("%q cannot be both a patch and a pre-release", versionStr)
+	}
+	v.Language = !hasPatch && v.Pre == ""
+	return v, nil
 }
 
 // VersionFromString returns a Version created from a valid string version
-// of the form x.y.z or x.y
+// of the form x.y.z, x.y, x.ybetaN or x.yrcN, optionally go prefixed, x.y is
+// understood as the language version.
 func VersionFromString(versionStr string) (Version, error) {
-	versionParts := strings.Split(versionStr, ".")
-
-	if len(versionParts) < 2 || len(versionParts) > 3 {
-		return Version{}, errors.Errorf("%q is not a valid version string", versionStr)
-	}
+	return parseVersion(versionStr)
+}
 
-	major, err := strconv.Atoi(versionParts[0])
-	if err != nil {
-		return Version{}, errors.Wrapf(err, "%q is an invalid major", versionParts[0])
+// VersionFromName returns a Version created from a go release name such as
+// go1.7, go1.21.0 or go1.21rc2, before go 1.21 goX.Y named the X.Y.0 release
+// and it is parsed as such.
+func VersionFromName(name string) (Version, error) {
+	if len(name) <= len(namePrefix) || name[:len(namePrefix)] != namePrefix {
+		return Version{}, errors.Errorf("%q is not a go release name", name)
 	}
-	minor, err := strconv.Atoi(versionParts[1])
+	v, err := parseVersion(name)
 	if err != nil {
-		// This is an rc/beta most likely.
-		return Version{}, errors.Wrapf(err, "%q is an invalid minor", versionParts[1])
-	}
-
-	if len(versionParts) == 2 {
-		return Version{
-			Major: major,
-			Minor: minor,
-			Patch: 0,
-		}, nil
+		return Version{}, errors.WithStack(err)
 	}
-
-	if len(versionParts) == 3 {
-		patch, err := strconv.Atoi(versionParts[2])
-		if err != nil {
-			return Version{}, errors.Wrapf(err, "%q is an invalid patch", versionParts[2])
-		}
-		return Version{
-			Major: major,
-			Minor: minor,
-			Patch: patch,
-		}, nil
+	if v.Language && v.legacyRelease() {
+		v.Language = false
 	}
-	return Version{}, errors.Errorf("could not parse version string %q", versionStr)
+	return v, nil
 }
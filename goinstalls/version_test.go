@@ -0,0 +1,150 @@
+package goinstalls
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestVersionFromString(t *testing.T) {
+	for _, test := range []struct {
+		version string
+		want    Version
+	}{
+		{version: "1.21.3", want: Version{Major: 1, Minor: 21, Patch: 3}},
+		{version: "go1.21.3", want: Version{Major: 1, Minor: 21, Patch: 3}},
+		{version: "1.21.0", want: Version{Major: 1, Minor: 21}},
+		{version: "1.21", want: Version{Major: 1, Minor: 21, Language: true}},
+		{version: "1.7", want: Version{Major: 1, Minor: 7, Language: true}},
+		{version: "1", want: Version{Major: 1, Language: true}},
+		{version: "1.21rc2", want: Version{Major: 1, Minor: 21, Pre: PRERC, PreNumber: 2}},
+		{version: "go1.9beta1", want: Version{Major: 1, Minor: 9, Pre: PREBETA, PreNumber: 1}},
+		{version: "2.0", want: Version{Major: 2, Language: true}},
+	} {
+		t.Run(test.version, func(t *testing.T) {
+			v, err := VersionFromString(test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != test.want {
+				t.Errorf("parsed as %+v, want %+v", v, test.want)
+			}
+		})
+	}
+}
+
+func TestVersionFromStringFails(t *testing.T) {
+	for _, version := range []string{"", "go", "1.", "1.21.", "v1.21", "1.21.3rc1", "2", "1rc1", "1.21rc", "1.21alpha1", "1.21.3.4", "x.y", " 1.21"} {
+		if v, err := VersionFromString(version); err == nil {
+			t.Errorf("%q was parsed as %+v", version, v)
+		}
+	}
+}
+
+func TestVersionFromName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want Version
+	}{
+		// before 1.21 goX.Y named the X.Y.0 release.
+		{name: "go1", want: Version{Major: 1}},
+		{name: "go1.7", want: Version{Major: 1, Minor: 7}},
+		{name: "go1.20", want: Version{Major: 1, Minor: 20}},
+		{name: "go1.7.3", want: Version{Major: 1, Minor: 7, Patch: 3}},
+		{name: "go1.9beta1", want: Version{Major: 1, Minor: 9, Pre: PREBETA, PreNumber: 1}},
+		// since 1.21 goX.Y is the language version.
+		{name: "go1.21", want: Version{Major: 1, Minor: 21, Language: true}},
+		{name: "go1.21.0", want: Version{Major: 1, Minor: 21}},
+		{name: "go1.21rc2", want: Version{Major: 1, Minor: 21, Pre: PRERC, PreNumber: 2}},
+		{name: "go1.22.1", want: Version{Major: 1, Minor: 22, Patch: 1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := VersionFromName(test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != test.want {
+				t.Errorf("parsed as %+v, want %+v", v, test.want)
+			}
+			if v.Name() != test.name {
+				t.Errorf("named %q", v.Name())
+			}
+		})
+	}
+	for _, name := range []string{"", "go", "1.21.3", "go1.21.3rc1", "gox"} {
+		if v, err := VersionFromName(name); err == nil {
+			t.Errorf("%q was parsed as %+v", name, v)
+		}
+	}
+}
+
+func TestVersionStringRoundTrip(t *testing.T) {
+	for _, version := range []string{"1.21.3", "1.21.0", "1.21", "1.7", "1.7.3", "1.21rc2", "1.9beta1", "2.0", "2.1.4"} {
+		v, err := VersionFromString(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != version {
+			t.Errorf("%q is printed as %q", version, v.String())
+		}
+		again, err := VersionFromString(v.String())
+		if err != nil || again != v {
+			t.Errorf("%q parses back as %+v (%v), want %+v", v.String(), again, err, v)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	parse := func(s string) Version {
+		// g prefixed ones are release names.
+		if s[0] == 'g' {
+			v, err := VersionFromName(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+		v, err := VersionFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// from oldest to newest.
+	ordered := []string{
+		"go1", "go1.0.3",
+		"1.7", "go1.7beta1", "go1.7rc1", "go1.7rc2", "go1.7", "go1.7.3", "go1.7.10",
+		"go1.20", "go1.20.5",
+		"1.21", "1.21rc1", "1.21rc2", "1.21.0", "1.21.3",
+		"1.22", "1.22.1",
+		"2.0", "2.0.1",
+	}
+	versions := make([]Version, len(ordered))
+	for i, s := range ordered {
+		versions[i] = parse(s)
+	}
+	for i, v := range versions {
+		for j, other := range versions {
+			want := compareInts(i, j)
+			if got := v.Compare(other); got != want {
+				t.Errorf("%s compared to %s is %d, want %d", ordered[i], ordered[j], got, want)
+			}
+			if v.IsNewerThan(other) != (want > 0) {
+				t.Errorf("%s newer than %s is %v", ordered[i], ordered[j], v.IsNewerThan(other))
+			}
+		}
+	}
+
+	shuffled := Versions{}
+	for i := len(versions) - 1; i >= 0; i -= 2 {
+		shuffled = append(shuffled, versions[i])
+	}
+	for i := len(versions) % 2; i < len(versions); i += 2 {
+		shuffled = append(shuffled, versions[i])
+	}
+	sort.Sort(shuffled)
+	for i, v := range shuffled {
+		if v != versions[i] {
+			t.Errorf("sorted %s where %s was expected", v, ordered[i])
+		}
+	}
+}
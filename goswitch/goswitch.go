@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/perrito666/goworkon/environment"
@@ -28,14 +29,138 @@ const (
 	PREVPS1 = "GOWORKON_PREVIOUS_PS1"
 	// PREVCDPATH is the name of the variable used to backup CDPATH.
 	PREVCDPATH = "GOWORKON_PREVIOUS_CDPATH"
+	// VARS is the name of the variable holding the comma separated names
+	// of the variables set from the Vars of the current environment.
+	VARS = "GOWORKON_VARS"
+	// UNSETVARS is the name of the variable holding the comma separated
+	// names of the variables in VARS that were not set before switching.
+	UNSETVARS = "GOWORKON_UNSET_VARS"
+	// PREVVARPREFIX is prepended to the names of the variables in VARS to
+	// name the variables used to backup them.
+	PREVVARPREFIX = "GOWORKON_PREVIOUS_VAR_"
+	// PATHVARS is the name of the variable holding the PATH entries, from
+	// the Vars of the current environment, that were not in PATH before
+	// switching.
+	PATHVARS = "GOWORKON_PATH_VARS"
 )
 
 func setenv(varName, varValue string) string {
 	return fmt.Sprintf("%s=%s", varName, varValue)
 }
 
+func unsetenv(varName string) string {
+	return fmt.Sprintf("unset %s", varName)
+}
+
+// shellQuote returns value quoted so the shell takes it literally.
+func shellQuote(value string) string {
+	if !strings.ContainsAny(value, "\n\r") {
+		return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+	}
+	// the lines are read one by one, so line breaks are escaped.
+	quoted := strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\r", `\r`).Replace(value)
+	return "$'" + quoted + "'"
+}
+
+// pathWithout returns path without the entries in the PATH list members.
+func pathWithout(path, members string) string {
+	drop := map[string]bool{}
+	for _, member := range strings.Split(members, paths.PATHSEPARATOR) {
+		drop[member] = true
+	}
+	kept := []string{}
+	for _, member := range strings.Split(path, paths.PATHSEPARATOR) {
+		if !drop[member] {
+			kept = append(kept, member)
+		}
+	}
+	return strings.Join(kept, paths.PATHSEPARATOR)
+}
+
+// varNames returns the set of names in the comma separated list of the
+// variable listVar.
+func varNames(listVar string) map[string]bool {
+	names := map[string]bool{}
+	for _, name := range strings.Split(os.Getenv(listVar), ",") {
+		if name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// setList returns the line that sets listVar to the comma separated
+// names, or unsets it if there are none.
+func setList(listVar string, names map[string]bool) string {
+	if len(names) == 0 {
+		return unsetenv(listVar)
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return setenv(listVar, strings.Join(list, ","))
+}
+
+// restoreVar returns the lines that give name back the value it had before
+// an environment set it, or unset it if it had none.
+func restoreVar(name string, unset map[string]bool) []string {
+	if unset[name] {
+		return []string{unsetenv(name)}
+	}
+	return []string{
+		setenv(name, shellQuote(os.Getenv(PREVVARPREFIX+name))),
+		unsetenv(PREVVARPREFIX + name),
+	}
+}
+
+// switchVars returns the lines that export vars, backing up the values they
+// replace, and restore those set by the previous environment that vars
+// does not set. PATH is left out, it is built by Switch.
+func switchVars(vars map[string]string) []string {
+	current := varNames(VARS)
+	unset := varNames(UNSETVARS)
+	lines := []string{}
+	previous := make([]string, 0, len(current))
+	for name := range current {
+		previous = append(previous, name)
+	}
+	sort.Strings(previous)
+	for _, name := range previous {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		lines = append(lines, restoreVar(name, unset)...)
+		delete(unset, name)
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if name != PATH {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	set := map[string]bool{}
+	for _, name := range names {
+		// the value before the first environment set it is kept.
+		if !current[name] {
+			if value, ok := os.LookupEnv(name); ok {
+				lines = append(lines, setenv(PREVVARPREFIX+name, shellQuote(value)))
+			} else {
+				unset[name] = true
+			}
+		}
+		lines = append(lines, setenv(name, shellQuote(vars[name])))
+		set[name] = true
+	}
+	return append(lines, setList(VARS, set), setList(UNSETVARS, unset))
+}
+
 // Switch will set the proper environment variables to set an environment
-// as the current running one.
+// as the current running one, the Vars of the environment are exported
+// too and the values they replace backed up for Reset.
 func Switch(cfg environment.Config, isDefault bool, extraBin []string) error {
 	pgopath := os.Getenv(PREVGOPATH)
 	ppath := os.Getenv(PREVPATH)
@@ -45,6 +170,10 @@ func Switch(cfg environment.Config, isDefault bool, extraBin []string) error {
 	path := os.Getenv(PATH)
 	ps1 := os.Getenv(PS1)
 	cdpath := os.Getenv(CDPATH)
+	// the entries the previous environment added from its Vars go away.
+	if added := os.Getenv(PATHVARS); added != "" {
+		path = pathWithout(path, added)
+	}
 
 	envVars := []string{}
 	// backup vanilla paths.
@@ -52,7 +181,7 @@ func Switch(cfg environment.Config, isDefault bool, extraBin []string) error {
 		envVars = append(envVars, setenv(PREVGOPATH, gopath))
 	}
 	if ppath == "" && !isDefault {
-		envVars = append(envVars, setenv(PREVPATH, path))
+		envVars = append(envVars, setenv(PREVPATH, shellQuote(path)))
 	}
 	if pps1 == "" && !isDefault {
 		envVars = append(envVars, setenv(PREVPS1, fmt.Sprintf("\"%s\"", ps1)))
@@ -75,8 +204,27 @@ func Switch(cfg environment.Config, isDefault bool, extraBin []string) error {
 	if err != nil {
 		return errors.Wrapf(err, "trying to determine go installs path to switch to %q", cfg.Name)
 	}
-	newPath := paths.PATHInsert(path, paths.GoPathBin(cfg.GoPath), goInstallsPath)
-	envVars = append(envVars, setenv(PATH, newPath))
+	newMembers := []string{paths.GoPathBin(cfg.GoPath), goInstallsPath}
+	added := []string{}
+	if extra, ok := cfg.Vars[PATH]; ok {
+		current := map[string]bool{}
+		for _, member := range strings.Split(path, paths.PATHSEPARATOR) {
+			current[member] = true
+		}
+		for _, member := range strings.Split(extra, paths.PATHSEPARATOR) {
+			newMembers = append(newMembers, member)
+			if !current[member] {
+				added = append(added, member)
+			}
+		}
+	}
+	newPath := paths.PATHInsert(path, newMembers...)
+	envVars = append(envVars, setenv(PATH, shellQuote(newPath)))
+	if len(added) > 0 {
+		envVars = append(envVars, setenv(PATHVARS, shellQuote(strings.Join(added, paths.PATHSEPARATOR))))
+	} else {
+		envVars = append(envVars, unsetenv(PATHVARS))
+	}
 	// Default env does not need new ps1
 	if pps1 != "" {
 		ps1 = pps1
@@ -84,11 +232,14 @@ func Switch(cfg environment.Config, isDefault bool, extraBin []string) error {
 	if !isDefault {
 		envVars = append(envVars, setenv(PS1, fmt.Sprintf("\"%s(%s)$ \"", ps1, cfg.Name)))
 	}
+	envVars = append(envVars, switchVars(cfg.Vars)...)
 	fmt.Println(strings.Join(envVars, "\n"))
 	return nil
 }
 
-// Reset will set the environment to its previous state.
+// Reset will set the environment to its previous state, the variables
+// set from the Vars of the environment get their previous values back or
+// are unset if they had none.
 func Reset() error {
 	envVars := []string{
 		setenv(PREVPATH, ""),
@@ -100,13 +251,25 @@ func Reset() error {
 	envVars = append(envVars, setenv(GOPATH, pgopath))
 
 	if ppath != "" {
-		envVars = append(envVars, setenv(PATH, ppath))
+		envVars = append(envVars, setenv(PATH, shellQuote(ppath)))
+	} else if added := os.Getenv(PATHVARS); added != "" {
+		envVars = append(envVars, setenv(PATH, shellQuote(pathWithout(os.Getenv(PATH), added))))
 	}
 
 	pps1 := os.Getenv(PREVPS1)
 	if pps1 != "" {
 		envVars = append(envVars, setenv(PS1, fmt.Sprintf("\"%s\"", pps1)))
 	}
+	unset := varNames(UNSETVARS)
+	names := []string{}
+	for name := range varNames(VARS) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		envVars = append(envVars, restoreVar(name, unset)...)
+	}
+	envVars = append(envVars, unsetenv(VARS), unsetenv(UNSETVARS), unsetenv(PATHVARS))
 	fmt.Println(strings.Join(envVars, "\n"))
 	return nil
 }
@@ -0,0 +1,116 @@
+// Package lockfile provides locks, based on flock(2) on files holding the
+// PID of their owner, that serialize goworkon processes.
+package lockfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/juju/loggo"
+	"github.com/pkg/errors"
+)
+
+var logger = loggo.GetLogger("goworkon.lockfile")
+
+// pollInterval is how often a held lock is checked while waiting for it.
+var pollInterval = 200 * time.Millisecond
+
+// Lock is a held lock file.
+type Lock struct {
+	path string
+	fp   *os.File
+}
+
+// holder returns the PID written in the lock file in path, 0 if it holds
+// none yet.
+func holder(path string) int {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// tryAcquire opens the lock file in path and locks it, it returns nil and
+// the PID of the holder if another process holds it. The system releases
+// the locks of processes that exit, so lock files they leave behind are
+// simply taken over.
+func tryAcquire(path string) (*os.File, int, error) {
+	fp, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "opening lock %q", path)
+	}
+	if err := syscall.Flock(int(fp.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		fp.Close()
+		if err != syscall.EWOULDBLOCK {
+			return nil, 0, errors.Wrapf(err, "locking %q", path)
+		}
+		return nil, holder(path), nil
+	}
+	// the holder might have released it, removing the file, between us
+	// opening and locking it, the lock is only ours if the file we locked
+	// is still the one in path.
+	locked, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, 0, errors.Wrapf(err, "reading lock %q", path)
+	}
+	if current, err := os.Stat(path); err != nil || !os.SameFile(locked, current) {
+		fp.Close()
+		return nil, 0, nil
+	}
+	if pid := holder(path); pid != 0 && pid != os.Getpid() {
+		logger.Infof("taking over lock %q left by process %d", path, pid)
+	}
+	if err := fp.Truncate(0); err != nil {
+		fp.Close()
+		return nil, 0, errors.Wrapf(err, "writing lock %q", path)
+	}
+	if _, err := fmt.Fprintf(fp, "%d\n", os.Getpid()); err != nil {
+		fp.Close()
+		return nil, 0, errors.Wrapf(err, "writing lock %q", path)
+	}
+	return fp, 0, nil
+}
+
+// Acquire takes the lock file in path, waiting up to timeout for the
+// process holding it to release it, locks held by processes that no
+// longer exist are taken over.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	lastHolder := 0
+	for {
+		fp, pid, err := tryAcquire(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if fp != nil {
+			return &Lock{path: path, fp: fp}, nil
+		}
+		if pid != 0 && pid != lastHolder {
+			logger.Infof("waiting for process %d to release %q", pid, path)
+			lastHolder = pid
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("timed out after %s waiting for lock %q held by process %d",
+				timeout, path, lastHolder)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release releases the lock, the file is removed before it is unlocked
+// so waiting processes that lock it next know to open it again.
+func (l *Lock) Release() error {
+	defer l.fp.Close()
+	return errors.Wrapf(os.Remove(l.path), "releasing lock %q", l.path)
+}
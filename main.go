@@ -1,21 +1,18 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/juju/loggo"
 	flag "github.com/ogier/pflag"
 	"github.com/perrito666/goworkon/environment"
+	"github.com/perrito666/goworkon/goinstalls"
 	"github.com/perrito666/goworkon/paths"
+	"github.com/perrito666/goworkon/progress"
 	"github.com/pkg/errors"
 )
 
-// TODO(perrito666) this should be auto discovered from go repo.
-const currentGoVersion = "1.7"
-
 const (
 	// COMMANDSWITCH is the name of the switch-to-env command.
 	COMMANDSWITCH = "switch"
@@ -27,21 +24,69 @@ const (
 	COMMANDSET = "set"
 	// COMMANDLIST is the name if the list-environments command.
 	COMMANDLIST = "list"
+	// COMMANDINSTALLS is the name of the list-go-installs command.
+	COMMANDINSTALLS = "installs"
+	// COMMANDPRUNE is the name of the remove-unused-installs command.
+	COMMANDPRUNE = "prune"
+	// COMMANDREPAIR is the name of the reinstall-broken-install command.
+	COMMANDREPAIR = "repair"
+	// COMMANDINSTALL is the name of the install-go command.
+	COMMANDINSTALL = "install"
+	// COMMANDADOPT is the name of the register-existing-goroot command.
+	COMMANDADOPT = "adopt"
+	// COMMANDVERSIONS is the name of the list-go-versions command.
+	COMMANDVERSIONS = "versions"
+	// COMMANDVERIFY is the name of the check-installs-against-manifest command.
+	COMMANDVERIFY = "verify"
 )
 
 var (
 	// flags
-	goVersion string
+	goVersion      string
+	fromSource     bool
+	offline        bool
+	dryRun         bool
+	keepPatches    int
+	keepUsedWithin string
+	events         string
+	fromDir        string
+	fromGit        string
+	ref            string
+	fromArchive    string
+	installName    string
+	targetOS       string
+	targetArch     string
+	remote         bool
+	all            bool
+	asJSON         bool
+	jobs           int
 )
 
 var logger = loggo.GetLogger("goworkon")
 
 func init() {
 	//loggo.ConfigureLoggers(`<root>=DEBUG`)
-	flag.StringVar(&goVersion, "go-version", "", "the go version to be used (if none specified, all be updated)")
+	flag.StringVar(&goVersion, "go-version", "", "the go version or version constraint to be used, ie: 1.21.3, ~1.21, >=1.20 <1.22, stable or 1.22.3+v3 for a build variant (if none specified, all be updated)")
+	flag.BoolVar(&fromSource, "from-source", false, "compile go versions from source instead of using the prebuilt archives")
+	flag.BoolVar(&offline, "offline", false, "install go versions only from the download cache")
+	flag.BoolVar(&dryRun, "dry-run", false, "print what would be done without doing it")
+	flag.IntVar(&keepPatches, "keep-patches", 0, "the number of newest installs of each minor to keep when pruning")
+	flag.StringVar(&keepUsedWithin, "keep-used-within", "", "keep installs used within this period when pruning (ie: 30d)")
+	flag.StringVar(&fromDir, "from-dir", "", "build a custom install from this go tree")
+	flag.StringVar(&fromGit, "from-git", "", "build a custom install from this go git repository")
+	flag.StringVar(&ref, "ref", "", "the commit, branch or tag to build with --from-git (HEAD by default)")
+	flag.StringVar(&fromArchive, "from-archive", "", "make a custom install from this tar.gz holding a go folder")
+	flag.StringVar(&installName, "name", "", "the name of the custom install (devel-<revision> by default)")
+	flag.StringVar(&targetOS, "os", "", "the GOOS to install go for (the host one by default)")
+	flag.StringVar(&targetArch, "arch", "", "the GOARCH to install go for (the host one by default)")
+	flag.IntVar(&jobs, "jobs", 0, "the number of go versions to install at the same time (4 by default)")
+	flag.BoolVar(&remote, "remote", false, "list the go versions that can be installed instead of the installed ones")
+	flag.BoolVar(&all, "all", false, "list every release, not only the newest stable of each minor")
+	flag.BoolVar(&asJSON, "json", false, "print the list as JSON")
+	flag.StringVar(&events, "events", "", "report install progress as JSON lines on stderr when set to json")
 }
 
-func checkCommand(s environment.Settings) (Command, error) {
+func checkCommand(s environment.Settings, opts goinstalls.InstallOptions) (Command, error) {
 	if flag.NArg() == 0 {
 		return nil, errors.New("no command specified")
 	}
@@ -51,20 +96,19 @@ func checkCommand(s environment.Settings) (Command, error) {
 			environmentName: flag.Arg(1),
 		}, nil
 	case COMMANDCREATE:
-		if goVersion == "" {
-			goVersion = currentGoVersion
-		}
-
 		return Create{
 			environmentName: flag.Arg(1),
 			goPath:          flag.Arg(2),
 			goVersion:       goVersion,
 			settings:        s,
+			opts:            opts,
 		}, nil
 	case COMMANDUPDATE:
 		return Update{
 			environmentName: flag.Arg(1),
 			goVersion:       goVersion,
+			settings:        s,
+			opts:            opts,
 		}, nil
 	case COMMANDSET:
 		return Set{
@@ -74,21 +118,53 @@ func checkCommand(s environment.Settings) (Command, error) {
 
 	case COMMANDLIST:
 		return List{}, nil
+	case COMMANDINSTALLS:
+		return Installs{}, nil
+	case COMMANDPRUNE:
+		return Prune{
+			keepPatches:    keepPatches,
+			keepUsedWithin: keepUsedWithin,
+			dryRun:         dryRun,
+			settings:       s,
+		}, nil
+	case COMMANDREPAIR:
+		return Repair{
+			goVersion: flag.Arg(1),
+			settings:  s,
+			opts:      opts,
+		}, nil
+	case COMMANDINSTALL:
+		return Install{
+			goVersions:  flag.Args()[1:],
+			fromDir:     fromDir,
+			fromGit:     fromGit,
+			ref:         ref,
+			fromArchive: fromArchive,
+			name:        installName,
+			settings:    s,
+			opts:        opts,
+		}, nil
+	case COMMANDADOPT:
+		return Adopt{
+			goroot:   flag.Arg(1),
+			settings: s,
+		}, nil
+	case COMMANDVERIFY:
+		return Verify{
+			installName: flag.Arg(1),
+		}, nil
+	case COMMANDVERSIONS:
+		return Versions{
+			remote:   remote,
+			all:      all,
+			asJSON:   asJSON,
+			settings: s,
+		}, nil
 	}
 
 	return nil, errors.Errorf("Unknown command %q\n", flag.Arg(0))
 }
 
-func promptData(query string) (string, error) {
-	stdin := bufio.NewReader(os.Stdin)
-	fmt.Print(query)
-	answer, err := stdin.ReadString('\n')
-	if err != nil {
-		return "", errors.WithStack(err)
-	}
-	return strings.Trim(answer, "\n"), nil
-}
-
 func main() {
 	var err error
 	fail := func() {
@@ -108,15 +184,26 @@ func main() {
 	if err != nil {
 		fail()
 	}
-	if settings.Goroot == "" {
-		settings.Goroot, err = promptData("Please provide a valid GOROOT path: ")
-		if err != nil {
-			fail()
-		}
-		settings.Save(dataDir)
+	if fromSource {
+		settings.FromSource = true
+	}
+	if offline {
+		settings.Offline = true
+	}
+	if jobs != 0 {
+		settings.Concurrency = jobs
+	}
+	// the platform and progress reporter are chosen for each run.
+	opts := goinstalls.InstallOptions{
+		Platform: goinstalls.Platform{OS: targetOS, Arch: targetArch},
+	}
+	// stdout is reserved for the output of commands such as switch.
+	opts.Progress, err = progress.New(events, os.Stderr)
+	if err != nil {
+		fail()
 	}
 
-	c, err := checkCommand(settings)
+	c, err := checkCommand(settings, opts)
 	if err != nil {
 		fail()
 	}
@@ -20,6 +20,12 @@ const (
 	// UNIXXDGDATAHOME holds the name of the variable that might contain
 	// the path to XDG data home.
 	UNIXXDGDATAHOME = "XDG_DATA_HOME"
+	// UNIXDEFAULTXDGCACHEREL holds the default relative path for
+	// xdg cache (relative to $HOME)
+	UNIXDEFAULTXDGCACHEREL = ".cache"
+	// UNIXXDGCACHEHOME holds the name of the variable that might contain
+	// the path to XDG cache home.
+	UNIXXDGCACHEHOME = "XDG_CACHE_HOME"
 
 	// PATHSEPARATOR holds the character used to separate PATH members.
 	PATHSEPARATOR = ":"
@@ -32,6 +38,12 @@ const (
 	// INSTALLSFOLDER holds the name of the go install s folder insde
 	// goworkon xdg home.
 	INSTALLSFOLDER = "installs"
+	// LOCKSFOLDER holds the name of the lock files folder inside
+	// goworkon xdg home.
+	LOCKSFOLDER = "locks"
+	// DOWNLOADSFOLDER holds the name of the downloaded archives folder
+	// inside goworkon xdg cache.
+	DOWNLOADSFOLDER = "downloads"
 )
 
 // XdgData returns the most likely place for XDG data to be
@@ -50,6 +62,32 @@ func XdgData() (string, error) {
 	return filepath.Join(home, UNIXDEFAULTXDGDATAREL, GOWORKONNAME), nil
 }
 
+// XdgCache returns the most likely place for XDG cache to be
+// this will most likely notwork in windows.
+func XdgCache() (string, error) {
+	xdgCache := os.Getenv(UNIXXDGCACHEHOME)
+	if xdgCache != "" {
+		return filepath.Join(xdgCache, GOWORKONNAME), nil
+	}
+	home := os.Getenv(UNIXHOMEVAR)
+	if home == "" {
+		return "", errors.Errorf("cannot determine $%s", UNIXHOMEVAR)
+	}
+	// XDG standard says $HOME/.cache/$PROJECTNAME is the
+	// fallback if the variable is not set.
+	return filepath.Join(home, UNIXDEFAULTXDGCACHEREL, GOWORKONNAME), nil
+}
+
+// XdgCacheDownloads returns the folder where downloaded go archives
+// are kept.
+func XdgCacheDownloads() (string, error) {
+	xdgCacheDir, err := XdgCache()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(xdgCacheDir, DOWNLOADSFOLDER), nil
+}
+
 // XdgDataConfig returns the folder where config should be stored for
 // environments.
 func XdgDataConfig() (string, error) {
@@ -69,6 +107,15 @@ func XdgDataGoInstalls() (string, error) {
 	return filepath.Join(xdgDataDir, INSTALLSFOLDER), nil
 }
 
+// XdgDataLocks returns the folder where lock files are created.
+func XdgDataLocks() (string, error) {
+	xdgDataDir, err := XdgData()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(xdgDataDir, LOCKSFOLDER), nil
+}
+
 // XdgDataGoInstallsBinForVerson returns the bin path of the given go version.
 func XdgDataGoInstallsBinForVerson(goVersion string) (string, error) {
 	installs, err := XdgDataGoInstalls()
@@ -0,0 +1,287 @@
+// Package progress reports the progress of long running goworkon tasks
+// such as downloads, extractions and builds.
+package progress
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// STAGEDOWNLOAD is the stage of archive downloads.
+	STAGEDOWNLOAD = "download"
+	// STAGEEXTRACT is the stage of archive extractions.
+	STAGEEXTRACT = "extract"
+	// STAGEBUILD is the stage of source builds.
+	STAGEBUILD = "build"
+
+	// EVENTSTART is sent when a stage starts.
+	EVENTSTART = "start"
+	// EVENTPROGRESS is sent as bytes of a stage are processed.
+	EVENTPROGRESS = "progress"
+	// EVENTLINE is sent for each line of output of a stage.
+	EVENTLINE = "line"
+	// EVENTDONE is sent when a stage finishes successfully.
+	EVENTDONE = "done"
+	// EVENTERROR is sent when a stage fails.
+	EVENTERROR = "error"
+
+	// MODEJSON writes events as JSON lines.
+	MODEJSON = "json"
+
+	// reportInterval is the minimum time between progress events.
+	reportInterval = 200 * time.Millisecond
+)
+
+// Event describes something that happened to a stage of a task.
+type Event struct {
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+	// Subject is what the task works on, ie: the version being installed.
+	Subject string `json:"subject"`
+	// Stage is one of STAGEDOWNLOAD, STAGEEXTRACT or STAGEBUILD.
+	Stage string `json:"stage"`
+	// Kind is one of the EVENT* constants.
+	Kind string `json:"event"`
+	// Bytes is the amount of bytes processed so far.
+	Bytes int64 `json:"bytes,omitempty"`
+	// Total is the amount of bytes to process, zero if unknown.
+	Total int64 `json:"total,omitempty"`
+	// Rate is the amount of bytes processed per second.
+	Rate float64 `json:"rate,omitempty"`
+	// ETA is the estimated amount of seconds left, only progress events
+	// have it and it is zero if unknown.
+	ETA float64 `json:"eta,omitempty"`
+	// Message is the output line or error of the event.
+	Message string `json:"message,omitempty"`
+}
+
+// Reporter receives progress events, implementations must be safe for
+// concurrent use.
+type Reporter interface {
+	// Report handles the given event.
+	Report(Event)
+}
+
+type discard struct{}
+
+// Report implements Reporter.
+func (discard) Report(Event) {}
+
+// Discard is a Reporter that ignores all events.
+var Discard Reporter = discard{}
+
+// isTerminal returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	i, err := f.Stat()
+	return err == nil && i.Mode()&os.ModeCharDevice != 0
+}
+
+// New returns the Reporter for the given mode writing into f, the empty
+// mode is a progress bar if f is a terminal or plain lines otherwise.
+func New(mode string, f *os.File) (Reporter, error) {
+	switch mode {
+	case MODEJSON:
+		return NewJSON(f), nil
+	case "":
+		if isTerminal(f) {
+			return NewTTY(f), nil
+		}
+		return NewPlain(f), nil
+	}
+	return nil, errors.Errorf("%q is not a valid events mode, only %q is supported", mode, MODEJSON)
+}
+
+// Task tracks the progress of one stage and reports it.
+type Task struct {
+	mu         sync.Mutex
+	reporter   Reporter
+	subject    string
+	stage      string
+	total      int64
+	start      int64
+	done       int64
+	started    time.Time
+	lastReport time.Time
+	// lines holds the writers returned by Lines.
+	lines []*lineWriter
+}
+
+// Start reports the start of stage for subject and returns its Task,
+// total is the amount of bytes to process or zero if unknown.
+func Start(r Reporter, subject, stage string, total int64) *Task {
+	if r == nil {
+		r = Discard
+	}
+	t := &Task{
+		reporter: r,
+		subject:  subject,
+		stage:    stage,
+		total:    total,
+		started:  time.Now(),
+	}
+	t.report(EVENTSTART, "")
+	return t
+}
+
+// event returns an event of the given kind with the current progress,
+// the caller must hold t.mu.
+func (t *Task) event(kind, message string) Event {
+	now := time.Now()
+	e := Event{
+		Time:    now,
+		Subject: t.subject,
+		Stage:   t.stage,
+		Kind:    kind,
+		Bytes:   t.done,
+		Total:   t.total,
+		Message: message,
+	}
+	elapsed := now.Sub(t.started).Seconds()
+	if elapsed > 0 && t.done > t.start {
+		e.Rate = float64(t.done-t.start) / elapsed
+		if kind == EVENTPROGRESS && t.total > t.done {
+			e.ETA = float64(t.total-t.done) / e.Rate
+		}
+	}
+	return e
+}
+
+func (t *Task) report(kind, message string) {
+	t.mu.Lock()
+	e := t.event(kind, message)
+	t.lastReport = e.Time
+	t.mu.Unlock()
+	t.reporter.Report(e)
+}
+
+// Resume marks the first n bytes as processed before the task started,
+// they do not count for the rate.
+func (t *Task) Resume(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = n
+	t.done = n
+}
+
+// Resumed returns the amount of bytes processed before the task started.
+func (t *Task) Resumed() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.start
+}
+
+// SetTotal sets the amount of bytes to process once it is known.
+func (t *Task) SetTotal(total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = total
+}
+
+// Add marks n more bytes as processed, progress is reported at most
+// every reportInterval.
+func (t *Task) Add(n int64) {
+	t.mu.Lock()
+	t.done += n
+	due := time.Since(t.lastReport) >= reportInterval
+	t.mu.Unlock()
+	if due {
+		t.report(EVENTPROGRESS, "")
+	}
+}
+
+// Line reports a line of output of the stage.
+func (t *Task) Line(line string) {
+	t.report(EVENTLINE, line)
+}
+
+// Done reports the end of the stage, failed if err is not nil, after the
+// incomplete lines left in the writers returned by Lines, if any.
+func (t *Task) Done(err error) {
+	t.mu.Lock()
+	lines := t.lines
+	t.mu.Unlock()
+	for _, w := range lines {
+		w.flush()
+	}
+	if err != nil {
+		t.report(EVENTERROR, err.Error())
+		return
+	}
+	t.report(EVENTDONE, "")
+}
+
+// Write implements io.Writer counting the written bytes as processed.
+func (t *Task) Write(p []byte) (int, error) {
+	t.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// lineWriter is an io.Writer that reports every complete line written
+// to it.
+type lineWriter struct {
+	mu   sync.Mutex
+	task *Task
+	buf  bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// incomplete line, keep it for the next write.
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(p), nil
+		}
+		w.task.Line(line[:len(line)-1])
+	}
+}
+
+// flush reports the incomplete line left, if any.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.task.Line(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+// Lines returns an io.Writer that reports each line written to it as a
+// line of the task output.
+func (t *Task) Lines() io.Writer {
+	w := &lineWriter{task: t}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, w)
+	return w
+}
+
+// countingReader counts the bytes read from it as processed by a task.
+type countingReader struct {
+	r    io.Reader
+	task *Task
+}
+
+// Read implements io.Reader.
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.task.Add(int64(n))
+	return n, err
+}
+
+// Reader returns an io.Reader that reads from r counting the bytes as
+// processed.
+func (t *Task) Reader(r io.Reader) io.Reader {
+	return countingReader{r: r, task: t}
+}
@@ -0,0 +1,168 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// barWidth is the amount of characters of the TTY progress bar.
+const barWidth = 30
+
+// ttyLineWidth is the maximum amount of characters of output lines shown
+// on the TTY progress line.
+const ttyLineWidth = 80
+
+// plainInterval is the minimum time between plain progress lines of a
+// task.
+const plainInterval = 5 * time.Second
+
+// humanBytes returns a human readable representation of n bytes.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// describe returns the bytes, rate and ETA of a progress event.
+func describe(e Event) string {
+	if e.Bytes == 0 && e.Total == 0 {
+		return ""
+	}
+	parts := []string{humanBytes(e.Bytes)}
+	if e.Total > 0 {
+		parts[0] += "/" + humanBytes(e.Total)
+	}
+	if e.Rate > 0 {
+		parts = append(parts, humanBytes(int64(e.Rate))+"/s")
+	}
+	if e.ETA > 0 {
+		eta := time.Duration(e.ETA * float64(time.Second)).Round(time.Second)
+		parts = append(parts, "ETA "+eta.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// jsonReporter writes each event as a JSON line.
+type jsonReporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSON returns a Reporter that writes every event into w as a line
+// of JSON.
+func NewJSON(w io.Writer) Reporter {
+	return &jsonReporter{encoder: json.NewEncoder(w)}
+}
+
+// Report implements Reporter.
+func (r *jsonReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// there is nothing sensible to do if progress cannot be written.
+	r.encoder.Encode(e)
+}
+
+// plainReporter writes a line per relevant event, it is meant for
+// outputs that are not terminals such as CI logs.
+type plainReporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	lastLine map[string]time.Time
+}
+
+// NewPlain returns a Reporter that writes plain lines into w, progress
+// is written at most every plainInterval per task.
+func NewPlain(w io.Writer) Reporter {
+	return &plainReporter{w: w, lastLine: map[string]time.Time{}}
+}
+
+// Report implements Reporter.
+func (r *plainReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := e.Subject + " " + e.Stage
+	prefix := fmt.Sprintf("%s %s:", e.Subject, e.Stage)
+	switch e.Kind {
+	case EVENTSTART:
+		r.lastLine[key] = e.Time
+		fmt.Fprintln(r.w, prefix, "started")
+	case EVENTPROGRESS:
+		if e.Time.Sub(r.lastLine[key]) < plainInterval {
+			return
+		}
+		r.lastLine[key] = e.Time
+		fmt.Fprintln(r.w, prefix, describe(e))
+	case EVENTLINE:
+		fmt.Fprintln(r.w, prefix, e.Message)
+	case EVENTDONE:
+		delete(r.lastLine, key)
+		fmt.Fprintln(r.w, strings.TrimSpace(prefix+" done "+describe(e)))
+	case EVENTERROR:
+		delete(r.lastLine, key)
+		fmt.Fprintln(r.w, prefix, "failed:", e.Message)
+	}
+}
+
+// ttyReporter draws a progress bar on a terminal, rewriting the current
+// line as progress is made.
+type ttyReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTTY returns a Reporter that draws a progress bar into the terminal
+// w.
+func NewTTY(w io.Writer) Reporter {
+	return &ttyReporter{w: w}
+}
+
+// bar returns a progress bar for the given event.
+func bar(e Event) string {
+	if e.Total <= 0 {
+		return ""
+	}
+	done := int(e.Bytes * barWidth / e.Total)
+	if done > barWidth {
+		done = barWidth
+	}
+	return fmt.Sprintf("[%s%s] %3d%% ",
+		strings.Repeat("=", done),
+		strings.Repeat(" ", barWidth-done),
+		e.Bytes*100/e.Total)
+}
+
+// Report implements Reporter.
+func (r *ttyReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prefix := fmt.Sprintf("%s %s: ", e.Subject, e.Stage)
+	// \r goes back to the start of the line and \x1b[K clears what is
+	// left of the previous one.
+	switch e.Kind {
+	case EVENTSTART:
+		fmt.Fprintf(r.w, "\r%s\x1b[K", prefix)
+	case EVENTPROGRESS:
+		fmt.Fprintf(r.w, "\r%s%s%s\x1b[K", prefix, bar(e), describe(e))
+	case EVENTLINE:
+		line := e.Message
+		if len(line) > ttyLineWidth {
+			line = line[:ttyLineWidth-3] + "..."
+		}
+		fmt.Fprintf(r.w, "\r%s%s\x1b[K", prefix, line)
+	case EVENTDONE:
+		fmt.Fprintf(r.w, "\r%sdone %s\x1b[K\n", prefix, describe(e))
+	case EVENTERROR:
+		fmt.Fprintf(r.w, "\r%sfailed: %s\x1b[K\n", prefix, e.Message)
+	}
+}